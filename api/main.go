@@ -2,15 +2,22 @@ package main
 
 import (
 	"context"
-	_ "encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	_ "log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 
+	"go-llm-tools/internal/accounting"
 	"go-llm-tools/internal/auth"
 	"go-llm-tools/internal/chain"
 	"go-llm-tools/internal/chatgpt"
@@ -27,6 +34,10 @@ type ChatRequest struct {
 	Model     string            `json:"model"`
 	Variables map[string]string `json:"variables"`
 	ChainMode bool              `json:"chain_mode"`
+	// User 计费归属的用户或团队，为空时使用 X-User-ID 请求头
+	User string `json:"user"`
+	// Attachments 随问题发送的图片或文件，需要支持多模态的模型
+	Attachments []llm.ContentPart `json:"attachments"`
 }
 
 type ChatResponse struct {
@@ -35,7 +46,11 @@ type ChatResponse struct {
 	Template   string `json:"template"`
 	Model      string `json:"model"`
 	TokenUsage int    `json:"token_usage,omitempty"`
-	Error      string `json:"error,omitempty"`
+	// Cost 本次调用的费用（美元）
+	Cost float64 `json:"cost,omitempty"`
+	// Cached 回答来自响应缓存
+	Cached bool   `json:"cached,omitempty"`
+	Error  string `json:"error,omitempty"`
 }
 
 type TemplateRequest struct {
@@ -75,14 +90,24 @@ type ChatGPTRequest struct {
 // 全局变量
 var (
 	provider      llm.Provider
+	registry      *llm.Registry
 	promptEngine  *prompt.PromptEngine
 	ragEngine     *rag.RAGEngine
 	config        *utils.Config
 	logger        *logrus.Logger
 	authManager   *auth.AuthManager
 	chatGPTClient *chatgpt.ChatGPTClient
+	ledger        *accounting.Ledger
+	metrics       *llm.LatencyMetrics
+	hedgeBudget   *llm.HedgeBudget
 )
 
+// ragChainName 检索增强问答调用链在用量统计中的名称
+const ragChainName = "rag_qa"
+
+// streamFirstChunkTimeout 流式接口等待第一个数据块的最长时间，开始输出之后不再限时
+const streamFirstChunkTimeout = 30 * time.Second
+
 func main() {
 	// 初始化日志
 	logger = logrus.New()
@@ -137,17 +162,33 @@ func initializeComponents() {
 	// 初始化 ChatGPT 客户端
 	chatGPTClient = chatgpt.NewChatGPTClient("https://chat.openai.com")
 
-	// 初始化 LLM 提供者
-	llmConfig := &llm.Config{
-		APIKey:      config.OpenAIAPIKey,
-		BaseURL:     config.OpenAIBaseURL,
-		Model:       config.OpenAIModel,
-		Temperature: config.OpenAITemperature,
-		MaxTokens:   config.OpenAIMaxTokens,
-		Timeout:     config.RequestTimeout,
+	// 初始化 LLM 提供者注册表，请求中的 model 字段决定使用哪个后端
+	registry = llm.DefaultRegistry
+	utils.ConfigureRegistry(registry, config)
+
+	// 所有后端的调用共用同一组中间件：调用日志和延迟统计
+	metrics = llm.NewLatencyMetrics()
+	registry.Use(utils.ProviderMiddlewares(config, logger, metrics)...)
+
+	// 启用对冲时，默认后端超过 p95 延迟仍未返回会向其他后端或 Key 再发一次请求
+	hedgeBudget = utils.ConfigureHedging(registry, config, metrics)
+
+	var err error
+	provider, err = registry.Provider(llm.ModelType(config.LLMProvider))
+	if err != nil {
+		logger.Fatalf("Failed to create LLM provider: %v", err)
 	}
 
-	provider = llm.NewOpenAIProvider(llmConfig)
+	// 加载分词器词表，用于按模型上下文长度裁剪 Prompt
+	if err := utils.LoadTokenizers(config); err != nil {
+		logger.Warnf("Failed to load tokenizers: %v", err)
+	}
+
+	// 初始化用量账本，按模型单价统计各用户、模板和调用链的费用
+	if err := utils.ConfigurePricing(config); err != nil {
+		logger.Warnf("Failed to configure model prices: %v", err)
+	}
+	ledger = accounting.NewLedger()
 
 	// 初始化 Prompt 引擎
 	promptEngine = prompt.NewPromptEngine()
@@ -186,6 +227,7 @@ func setupRoutes(r *gin.Engine) {
 
 		// 聊天接口
 		v1.POST("/chat", handleChat)
+		v1.POST("/chat/stream", handleChatStream)
 
 		// 模板管理
 		v1.GET("/templates", handleListTemplates)
@@ -199,6 +241,11 @@ func setupRoutes(r *gin.Engine) {
 
 		// 健康检查
 		v1.GET("/health", handleHealth)
+
+		// 用量与费用统计
+		v1.GET("/usage", handleUsage)
+		v1.GET("/cache/stats", handleCacheStats)
+		v1.GET("/metrics", handleMetrics)
 	}
 
 	// 根路径
@@ -348,19 +395,22 @@ func handleDeleteConversation(c *gin.Context) {
 }
 
 func handleChat(c *gin.Context) {
+	// 兼容 ?stream=true 的流式调用方式
+	if c.Query("stream") == "true" {
+		handleChatStream(c)
+		return
+	}
+
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// 设置默认值
-	if req.Template == "" {
-		req.Template = "qa"
-	}
-	if req.Model == "" {
-		req.Model = provider.GetConfig().Model
+	if err := validateAttachments(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	setChatDefaults(c, &req)
 
 	var response ChatResponse
 	response.Query = req.Query
@@ -370,48 +420,309 @@ func handleChat(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	var (
+		result    string
+		resp      *llm.ChatResponse
+		err       error
+		chainName string
+	)
+	if req.ChainMode {
+		chainName = ragChainName
+	}
+	ctx = withCacheScope(ctx, req, chainName)
+
 	if req.ChainMode {
 		// 链式调用模式
-		result, err := runChainMode(ctx, req)
+		result, resp, err = runChainMode(ctx, req)
+	} else {
+		// 简单模式
+		result, resp, err = runSimpleMode(ctx, req)
+	}
+	if err != nil {
+		response.Error = err.Error()
+		c.JSON(llmErrorStatus(c, err), response)
+		return
+	}
+
+	response.Answer = result
+	if resp != nil {
+		response.TokenUsage = resp.Usage.TotalTokens
+		response.Cost = recordUsage(req, chainName, resp.Model, resp.Usage)
+		response.Cached = resp.Cached
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handleChatStream 以 Server-Sent Events 形式返回聊天结果
+//
+// 事件类型：delta（增量内容）、usage（Token 使用）、error（错误）、done（结束）。流总以 done 事件结束，
+// 出错时先发送 error 事件；收到第一个数据块之前的错误以 JSON 和对应的状态码返回，不开始输出。
+func handleChatStream(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateAttachments(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	setChatDefaults(c, &req)
+
+	// 只限制收到第一个数据块之前的时间，长回答可以一直输出；
+	// 客户端断开连接时 Request.Context 会被取消，上游调用随之中止
+	ctx, cancel := context.WithCancelCause(c.Request.Context())
+	defer cancel(nil)
+	firstChunkTimer := time.AfterFunc(streamFirstChunkTimeout, func() { cancel(context.DeadlineExceeded) })
+	defer firstChunkTimer.Stop()
+
+	var (
+		content   string
+		chainName string
+		err       error
+	)
+	if req.ChainMode {
+		chainName = ragChainName
+	}
+	ctx = withCacheScope(ctx, req, chainName)
+
+	if req.ChainMode {
+		content, err = newPromptChain().RunString(ctx, req.Query)
 		if err != nil {
-			response.Error = err.Error()
-			c.JSON(http.StatusInternalServerError, response)
-			return
+			err = fmt.Errorf("chain execution failed: %w", err)
 		}
-		response.Answer = result
 	} else {
-		// 简单模式
-		result, tokenUsage, err := runSimpleMode(ctx, req)
+		content, err = renderSimplePrompt(req)
+	}
+	if err != nil {
+		c.JSON(llmErrorStatus(c, err), gin.H{"error": err.Error()})
+		return
+	}
+
+	p, model, err := registry.ProviderFor(req.Model)
+	if err != nil {
+		c.JSON(llmErrorStatus(c, err), gin.H{"error": err.Error()})
+		return
+	}
+
+	llmReq := &llm.ChatRequest{
+		Model:       model,
+		Messages:    []llm.Message{userMessage(req, content)},
+		Temperature: p.GetConfig().Temperature,
+		MaxTokens:   p.GetConfig().MaxTokens,
+		Stream:      true,
+	}
+	if err := llm.FitChatRequest(llmReq); err != nil {
+		c.JSON(llmErrorStatus(c, err), gin.H{"error": err.Error()})
+		return
+	}
+
+	chunks, err := p.ChatStream(ctx, llmReq)
+	if err != nil {
+		err = fmt.Errorf("LLM call failed: %w", err)
+		c.JSON(llmErrorStatus(c, err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// 等到第一个数据块再开始输出，此前的错误仍可返回对应的状态码
+	first, pending := <-chunks
+	firstChunkTimer.Stop()
+	switch {
+	case ctx.Err() != nil:
+		err = fmt.Errorf("LLM call failed: %w", context.Cause(ctx))
+	case pending && first.Err != nil:
+		err = fmt.Errorf("LLM call failed: %w", first.Err)
+	}
+	if err != nil {
+		c.JSON(llmErrorStatus(c, err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// 流结束时按最后收到的用量计费
+	var (
+		usage     *llm.Usage
+		respModel = req.Model
+	)
+	// finish 发送结束事件，err 不为空时先发送 error 事件
+	finish := func(err error) {
 		if err != nil {
-			response.Error = err.Error()
-			c.JSON(http.StatusInternalServerError, response)
-			return
+			c.SSEvent("error", gin.H{"error": err.Error()})
 		}
-		response.Answer = result
-		response.TokenUsage = tokenUsage
+
+		done := gin.H{
+			"query":    req.Query,
+			"template": req.Template,
+			"model":    req.Model,
+		}
+		if usage != nil {
+			done["cost"] = recordUsage(req, chainName, respModel, *usage)
+		}
+		c.SSEvent("done", done)
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.Stream(func(w io.Writer) bool {
+		var chunk llm.ChatStreamChunk
+		if pending {
+			chunk, pending = first, false
+		} else {
+			var ok bool
+			if chunk, ok = <-chunks; !ok {
+				// 客户端断开后上游的流会直接关闭，不会带有错误
+				var err error
+				if ctx.Err() != nil {
+					err = fmt.Errorf("LLM call failed: %w", context.Cause(ctx))
+				}
+				finish(err)
+				return false
+			}
+		}
+
+		if chunk.Model != "" {
+			respModel = chunk.Model
+		}
+
+		if chunk.Err != nil {
+			finish(chunk.Err)
+			return false
+		}
+
+		if chunk.Content != "" || chunk.Role != "" || chunk.FinishReason != "" {
+			c.SSEvent("delta", gin.H{
+				"index":         chunk.Index,
+				"role":          chunk.Role,
+				"content":       chunk.Content,
+				"finish_reason": chunk.FinishReason,
+			})
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+			c.SSEvent("usage", chunk.Usage)
+		}
+		return true
+	})
 }
 
-func runChainMode(ctx context.Context, req ChatRequest) (string, error) {
-	// 创建链式调用
-	c := chain.NewChain()
+// llmErrorStatus 根据提供者错误分类返回 HTTP 状态码，上游要求等待时设置 Retry-After
+func llmErrorStatus(c *gin.Context, err error) int {
+	if retryAfter := llm.RetryAfter(err); retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+
+	switch {
+	case errors.Is(err, llm.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, llm.ErrAuth):
+		return http.StatusUnauthorized
+	case errors.Is(err, llm.ErrContextLength):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, llm.ErrUnsupportedContent), errors.Is(err, llm.ErrUnsupportedParameter):
+		return http.StatusBadRequest
+	case errors.Is(err, llm.ErrContentFiltered):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, llm.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, llm.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// setChatDefaults 设置聊天请求的默认值
+func setChatDefaults(c *gin.Context, req *ChatRequest) {
+	if req.Template == "" {
+		req.Template = "qa"
+	}
+	if req.User == "" {
+		req.User = c.GetHeader("X-User-ID")
+	}
+	if req.Model == "" {
+		req.Model = provider.GetConfig().Model
+	}
+}
 
-	// 添加步骤：检索 -> 构建 Prompt -> 调用 LLM
+// validateAttachments 检查附件的字段是否完整
+func validateAttachments(req ChatRequest) error {
+	for i, part := range req.Attachments {
+		if err := part.Validate(); err != nil {
+			return fmt.Errorf("invalid attachment %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// userMessage 创建携带附件的用户消息
+func userMessage(req ChatRequest, content string) llm.Message {
+	return llm.Message{Role: llm.RoleUser, Content: content, Parts: req.Attachments}
+}
+
+// newPromptChain 创建检索 -> 构建 Prompt 的链式调用
+func newPromptChain() *chain.Chain {
+	c := chain.NewChain()
 	c.AddStep(rag.Retrieve)
 	c.AddStep(prompt.BuildPrompt)
+	return c
+}
+
+// withCacheScope 按调用链、模板和模板变量隔离语义缓存，并用原始问题而不是渲染后的 Prompt 做匹配
+//
+// 变量不同时渲染出的 Prompt 不同，不能共用缓存，因此将变量的哈希加入作用域。
+func withCacheScope(ctx context.Context, req ChatRequest, chainName string) context.Context {
+	scope := chainName + "/" + req.Template
+	if len(req.Variables) > 0 {
+		// map 按键排序编码，相同的变量总是得到相同的哈希；map[string]string 的编码不会失败
+		data, _ := json.Marshal(req.Variables)
+		sum := sha256.Sum256(data)
+		scope += "/" + hex.EncodeToString(sum[:8])
+	}
+	return llm.WithSemanticScope(ctx, scope, req.Query)
+}
+
+// recordUsage 将一次 LLM 调用的用量计入账本，返回费用（美元）
+func recordUsage(req ChatRequest, chainName, model string, usage llm.Usage) float64 {
+	if model == "" {
+		model = req.Model
+	}
+	return ledger.Record(accounting.Entry{
+		User:     req.User,
+		Template: req.Template,
+		Chain:    chainName,
+		Model:    model,
+		Usage:    usage,
+	})
+}
+
+// runChainMode 执行检索增强的链式调用，返回回答和 LLM 响应（用于统计用量）
+func runChainMode(ctx context.Context, req ChatRequest) (string, *llm.ChatResponse, error) {
+	p, model, err := registry.ProviderFor(req.Model)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 创建链式调用：检索 -> 构建 Prompt -> 调用 LLM
+	var resp *llm.ChatResponse
+	c := newPromptChain()
 	c.AddStep(func(ctx context.Context, input interface{}) (interface{}, error) {
 		if str, ok := input.(string); ok {
 			// 调用 LLM
 			llmReq := &llm.ChatRequest{
-				Model:       req.Model,
-				Messages:    []llm.Message{{Role: "user", Content: str}},
-				Temperature: provider.GetConfig().Temperature,
-				MaxTokens:   provider.GetConfig().MaxTokens,
+				Model:       model,
+				Messages:    []llm.Message{userMessage(req, str)},
+				Temperature: p.GetConfig().Temperature,
+				MaxTokens:   p.GetConfig().MaxTokens,
+			}
+			if err := llm.FitChatRequest(llmReq); err != nil {
+				return nil, err
 			}
 
-			resp, err := provider.Chat(ctx, llmReq)
+			var err error
+			resp, err = p.Chat(ctx, llmReq)
 			if err != nil {
 				return nil, err
 			}
@@ -427,46 +738,64 @@ func runChainMode(ctx context.Context, req ChatRequest) (string, error) {
 
 	result, err := c.RunString(ctx, req.Query)
 	if err != nil {
-		return "", fmt.Errorf("chain execution failed: %w", err)
+		return "", nil, fmt.Errorf("chain execution failed: %w", err)
 	}
 
-	return result, nil
+	return result, resp, nil
 }
 
-func runSimpleMode(ctx context.Context, req ChatRequest) (string, int, error) {
-	// 渲染 Prompt 模板
-	data := map[string]interface{}{
-		"question": req.Query,
-	}
-
-	// 添加自定义变量
-	for k, v := range req.Variables {
-		data[k] = v
+// runSimpleMode 渲染模板后直接调用 LLM，返回回答和 LLM 响应（用于统计用量）
+func runSimpleMode(ctx context.Context, req ChatRequest) (string, *llm.ChatResponse, error) {
+	prompt, err := renderSimplePrompt(req)
+	if err != nil {
+		return "", nil, err
 	}
 
-	prompt, err := promptEngine.Render(req.Template, data)
+	p, model, err := registry.ProviderFor(req.Model)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to render prompt: %w", err)
+		return "", nil, err
 	}
 
 	// 调用 LLM
 	llmReq := &llm.ChatRequest{
-		Model:       req.Model,
-		Messages:    []llm.Message{{Role: "user", Content: prompt}},
-		Temperature: provider.GetConfig().Temperature,
-		MaxTokens:   provider.GetConfig().MaxTokens,
+		Model:       model,
+		Messages:    []llm.Message{userMessage(req, prompt)},
+		Temperature: p.GetConfig().Temperature,
+		MaxTokens:   p.GetConfig().MaxTokens,
+	}
+	if err := llm.FitChatRequest(llmReq); err != nil {
+		return "", nil, err
 	}
 
-	resp, err := provider.Chat(ctx, llmReq)
+	resp, err := p.Chat(ctx, llmReq)
 	if err != nil {
-		return "", 0, fmt.Errorf("LLM call failed: %w", err)
+		return "", nil, fmt.Errorf("LLM call failed: %w", err)
 	}
 
 	if len(resp.Choices) > 0 {
-		return resp.Choices[0].Message.Content, resp.Usage.TotalTokens, nil
+		return resp.Choices[0].Message.Content, resp, nil
 	}
 
-	return "抱歉，没有获得有效回复。", 0, nil
+	return "抱歉，没有获得有效回复。", resp, nil
+}
+
+// renderSimplePrompt 渲染简单模式使用的 Prompt 模板
+func renderSimplePrompt(req ChatRequest) (string, error) {
+	data := map[string]interface{}{
+		"question": req.Query,
+	}
+
+	// 添加自定义变量
+	for k, v := range req.Variables {
+		data[k] = v
+	}
+
+	prompt, err := promptEngine.Render(req.Template, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	return prompt, nil
 }
 
 func handleListTemplates(c *gin.Context) {
@@ -569,14 +898,81 @@ func handleAddDocument(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Document added successfully"})
 }
 
+// handleHealth 返回服务状态和各后端的熔断状态，有后端熔断或试探中时 status 为 degraded
 func handleHealth(c *gin.Context) {
+	status := "healthy"
+	providers := make(map[llm.ModelType]llm.BreakerStats)
+	for _, modelType := range registry.ModelTypes() {
+		providerConfig, ok := registry.Config(modelType)
+		if !ok || providerConfig == nil || providerConfig.Breaker == nil {
+			continue
+		}
+
+		stats := providerConfig.Breaker.Stats()
+		if stats.State != llm.BreakerClosed {
+			status = "degraded"
+		}
+		providers[modelType] = stats
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
+		"status":    status,
 		"timestamp": time.Now().Unix(),
 		"version":   "1.0.0",
+		"providers": providers,
 	})
 }
 
+// handleUsage 返回按用户、模板、调用链和模型汇总的用量与费用（美元）
+//
+// 可用 ?user= 只查看某个用户的汇总。
+func handleUsage(c *gin.Context) {
+	summary := ledger.Summary()
+
+	if user := c.Query("user"); user != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"user":   user,
+			"totals": summary.Users[user],
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// handleMetrics 返回按方法、后端和模型统计的调用延迟（毫秒），流式调用统计首个数据块的耗时
+//
+// 启用对冲请求时 hedge 为对冲统计，否则为 null。
+func handleMetrics(c *gin.Context) {
+	var hedge *llm.HedgeStats
+	if hedgeBudget != nil {
+		stats := hedgeBudget.Stats()
+		hedge = &stats
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"latency": metrics.Snapshot(),
+		"hedge":   hedge,
+	})
+}
+
+// handleCacheStats 返回各后端语义缓存的命中统计，没有后端启用语义缓存时 semantic 为 null
+func handleCacheStats(c *gin.Context) {
+	var stats map[llm.ModelType]llm.SemanticCacheStats
+	for _, modelType := range registry.ModelTypes() {
+		config, ok := registry.Config(modelType)
+		if !ok || config == nil || config.SemanticCache == nil {
+			continue
+		}
+		if stats == nil {
+			stats = make(map[llm.ModelType]llm.SemanticCacheStats)
+		}
+		stats[modelType] = config.SemanticCache.Stats()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"semantic": stats})
+}
+
 func handleRoot(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Go LLM Tools API",
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"log"
 	_ "os"
+	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
 	"go-llm-tools/internal/chain"
 	"go-llm-tools/internal/llm"
 	"go-llm-tools/internal/prompt"
@@ -20,14 +23,23 @@ func main() {
 	var (
 		query     = flag.String("query", "", "查询内容")
 		template  = flag.String("template", "qa", "使用的 Prompt 模板")
-		model     = flag.String("model", "gpt-3.5-turbo", "使用的模型")
+		model     = flag.String("model", "", "使用的模型，可用 \"后端/模型\" 指定后端（默认使用配置中的模型）")
 		apiKey    = flag.String("api-key", "", "OpenAI API Key")
 		baseURL   = flag.String("base-url", "", "OpenAI Base URL")
 		verbose   = flag.Bool("verbose", false, "详细输出")
 		chainMode = flag.Bool("chain", false, "使用链式调用模式")
+
+		attachments pathList
 	)
+	flag.Var(&attachments, "image", "随问题发送的图片路径，可重复指定，需要支持多模态的模型")
+	flag.Var(&attachments, "file", "随问题发送的文件路径，可重复指定；文本类文件在不支持文件的后端会作为文本发送")
 	flag.Parse()
 
+	parts, err := loadAttachments(attachments)
+	if err != nil {
+		log.Fatalf("Failed to load attachments: %v", err)
+	}
+
 	// 加载配置
 	config, err := utils.LoadConfig()
 	if err != nil {
@@ -42,22 +54,35 @@ func main() {
 	// 使用命令行参数覆盖配置
 	if *apiKey != "" {
 		config.OpenAIAPIKey = *apiKey
+		config.OpenAIAPIKeys = nil
 	}
 	if *baseURL != "" {
 		config.OpenAIBaseURL = *baseURL
 	}
 
-	// 创建 LLM 提供者
-	llmConfig := &llm.Config{
-		APIKey:      config.OpenAIAPIKey,
-		BaseURL:     config.OpenAIBaseURL,
-		Model:       *model,
-		Temperature: config.OpenAITemperature,
-		MaxTokens:   config.OpenAIMaxTokens,
-		Timeout:     config.RequestTimeout,
+	// 加载分词器词表，用于按模型上下文长度裁剪 Prompt
+	if err := utils.LoadTokenizers(config); err != nil {
+		log.Printf("Warning: failed to load tokenizers: %v", err)
 	}
 
-	provider := llm.NewOpenAIProvider(llmConfig)
+	// 用配置覆盖内置的模型单价，-verbose 时据此输出费用
+	if err := utils.ConfigurePricing(config); err != nil {
+		log.Printf("Warning: failed to configure model prices: %v", err)
+	}
+
+	// 根据 -model 选择后端，如 gpt-4o、claude-3-haiku-20240307、ollama/qwen2；为空时使用默认提供者
+	registry := llm.DefaultRegistry
+	utils.ConfigureRegistry(registry, config)
+	registry.Use(utils.ProviderMiddlewares(config, logrus.StandardLogger(), nil)...)
+
+	provider, modelName, err := registry.ProviderFor(*model)
+	if err != nil {
+		log.Fatalf("Failed to create LLM provider: %v", err)
+	}
+
+	if *verbose {
+		fmt.Printf("使用的后端: %s, 模型: %s\n", provider.GetModelType(), modelName)
+	}
 
 	// 创建 Prompt 引擎
 	promptEngine := prompt.NewPromptEngine()
@@ -78,14 +103,39 @@ func main() {
 
 	if *chainMode {
 		// 链式调用模式
-		runChainMode(provider, promptEngine, ragEngine, *query, *template, *verbose)
+		runChainMode(provider, modelName, promptEngine, ragEngine, *query, *template, parts, *verbose)
 	} else {
 		// 简单模式
-		runSimpleMode(provider, promptEngine, *query, *template, *verbose)
+		runSimpleMode(provider, modelName, promptEngine, *query, *template, parts, *verbose)
 	}
 }
 
-func runChainMode(provider llm.Provider, promptEngine *prompt.PromptEngine, ragEngine *rag.RAGEngine, query, templateName string, verbose bool) {
+// pathList 可重复指定的路径参数
+type pathList []string
+
+func (l *pathList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *pathList) Set(path string) error {
+	*l = append(*l, path)
+	return nil
+}
+
+// loadAttachments 读取附件
+func loadAttachments(paths []string) ([]llm.ContentPart, error) {
+	var parts []llm.ContentPart
+	for _, path := range paths {
+		part, err := llm.PartFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func runChainMode(provider llm.Provider, model string, promptEngine *prompt.PromptEngine, ragEngine *rag.RAGEngine, query, templateName string, parts []llm.ContentPart, verbose bool) {
 	if query == "" {
 		fmt.Println("请输入查询内容 (使用 -query 参数)")
 		return
@@ -93,6 +143,7 @@ func runChainMode(provider llm.Provider, promptEngine *prompt.PromptEngine, ragE
 
 	// 创建链式调用
 	c := chain.NewChain()
+	var resp *llm.ChatResponse
 
 	// 添加步骤：检索 -> 构建 Prompt -> 调用 LLM
 	c.AddStep(rag.Retrieve)
@@ -101,13 +152,17 @@ func runChainMode(provider llm.Provider, promptEngine *prompt.PromptEngine, ragE
 		if str, ok := input.(string); ok {
 			// 调用 LLM
 			req := &llm.ChatRequest{
-				Model:       provider.GetConfig().Model,
-				Messages:    []llm.Message{{Role: "user", Content: str}},
+				Model:       model,
+				Messages:    []llm.Message{{Role: "user", Content: str, Parts: parts}},
 				Temperature: provider.GetConfig().Temperature,
 				MaxTokens:   provider.GetConfig().MaxTokens,
 			}
+			if err := llm.FitChatRequest(req); err != nil {
+				return nil, err
+			}
 
-			resp, err := provider.Chat(ctx, req)
+			var err error
+			resp, err = provider.Chat(ctx, req)
 			if err != nil {
 				return nil, err
 			}
@@ -132,9 +187,13 @@ func runChainMode(provider llm.Provider, promptEngine *prompt.PromptEngine, ragE
 
 	fmt.Printf("查询: %s\n", query)
 	fmt.Printf("结果: %s\n", result)
+
+	if verbose && resp != nil {
+		printUsage(resp, model)
+	}
 }
 
-func runSimpleMode(provider llm.Provider, promptEngine *prompt.PromptEngine, query, templateName string, verbose bool) {
+func runSimpleMode(provider llm.Provider, model string, promptEngine *prompt.PromptEngine, query, templateName string, parts []llm.ContentPart, verbose bool) {
 	if query == "" {
 		fmt.Println("请输入查询内容 (使用 -query 参数)")
 		return
@@ -160,11 +219,14 @@ func runSimpleMode(provider llm.Provider, promptEngine *prompt.PromptEngine, que
 	defer cancel()
 
 	req := &llm.ChatRequest{
-		Model:       provider.GetConfig().Model,
-		Messages:    []llm.Message{{Role: "user", Content: prompt}},
+		Model:       model,
+		Messages:    []llm.Message{{Role: "user", Content: prompt, Parts: parts}},
 		Temperature: provider.GetConfig().Temperature,
 		MaxTokens:   provider.GetConfig().MaxTokens,
 	}
+	if err := llm.FitChatRequest(req); err != nil {
+		log.Fatalf("Prompt too long: %v", err)
+	}
 
 	resp, err := provider.Chat(ctx, req)
 	if err != nil {
@@ -176,13 +238,32 @@ func runSimpleMode(provider llm.Provider, promptEngine *prompt.PromptEngine, que
 		fmt.Printf("回答: %s\n", resp.Choices[0].Message.Content)
 
 		if verbose {
-			fmt.Printf("Token 使用: %d\n", resp.Usage.TotalTokens)
+			printUsage(resp, model)
 		}
 	} else {
 		fmt.Println("抱歉，没有获得有效回复。")
 	}
 }
 
+// printUsage 输出 Token 用量、费用和实际应答的后端，响应中没有模型名时按请求的 model 计费
+func printUsage(resp *llm.ChatResponse, model string) {
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	fmt.Printf("Token 使用: %d (输入 %d, 输出 %d)\n", resp.Usage.TotalTokens, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	if cost, ok := llm.Cost(model, resp.Usage); ok {
+		fmt.Printf("费用: $%.6f\n", cost)
+	} else {
+		fmt.Printf("费用: 未知（模型 %s 未配置单价）\n", model)
+	}
+	if resp.Provider != "" {
+		fmt.Printf("应答后端: %s\n", resp.Provider)
+	}
+	if resp.Cached {
+		fmt.Println("命中缓存: 是")
+	}
+}
+
 func addSampleDocuments(retriever *rag.SimpleRetriever) {
 	// 添加一些示例文档
 	docs := []*rag.Document{
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"go-llm-tools/internal/llm"
+	"go-llm-tools/internal/llm/llmtest"
+	"go-llm-tools/internal/prompt"
+	"go-llm-tools/internal/rag"
+)
+
+// captureStdout 执行 fn 并返回其写到标准输出的内容
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe error: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	fn()
+	w.Close()
+	return <-output
+}
+
+// newPromptEngine 创建加载了默认模板的 Prompt 引擎
+func newPromptEngine(t *testing.T) *prompt.PromptEngine {
+	t.Helper()
+	engine := prompt.NewPromptEngine()
+	for _, tmpl := range prompt.DefaultTemplates {
+		if err := engine.AddTemplate(tmpl); err != nil {
+			t.Fatalf("AddTemplate error: %v", err)
+		}
+	}
+	return engine
+}
+
+// newMockRegistry 创建只注册了测试提供者的注册表
+func newMockRegistry(mock *llmtest.MockProvider) *llm.Registry {
+	registry := llm.NewRegistry()
+	registry.Register(llmtest.ModelTypeMock, mock.Factory(), "mock-")
+	registry.Configure(llmtest.ModelTypeMock, &llm.Config{Model: "mock-model", Temperature: 0.2, MaxTokens: 256})
+	registry.SetDefault(llmtest.ModelTypeMock)
+	return registry
+}
+
+func TestRunSimpleMode(t *testing.T) {
+	mock := llmtest.NewMockProvider(nil)
+	mock.On(llmtest.Contains("什么是 RAG")).Reply("RAG 是检索增强生成。")
+
+	provider, model, err := newMockRegistry(mock).ProviderFor("")
+	if err != nil {
+		t.Fatalf("ProviderFor error: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		runSimpleMode(provider, model, newPromptEngine(t), "什么是 RAG", "qa", nil, true)
+	})
+
+	for _, want := range []string{"查询: 什么是 RAG", "回答: RAG 是检索增强生成。", "Token 使用:"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.Model != "mock-model" || req.Temperature != 0.2 || req.MaxTokens != 256 {
+		t.Errorf("request = model %q temperature %v max_tokens %d, want registry config", req.Model, req.Temperature, req.MaxTokens)
+	}
+	if !strings.Contains(req.Messages[0].Content, "请提供详细、准确的答案") {
+		t.Errorf("prompt = %q, want rendered qa template", req.Messages[0].Content)
+	}
+}
+
+func TestRunChainMode(t *testing.T) {
+	mock := llmtest.NewMockProvider(nil)
+	mock.On(llmtest.Contains("基于知识库检索")).Reply("LangChain 是一个框架。")
+
+	provider, model, err := newMockRegistry(mock).ProviderFor("mock-model")
+	if err != nil {
+		t.Fatalf("ProviderFor error: %v", err)
+	}
+
+	retriever := rag.NewSimpleRetriever()
+	addSampleDocuments(retriever)
+
+	output := captureStdout(t, func() {
+		runChainMode(provider, model, newPromptEngine(t), rag.NewRAGEngine(retriever), "LangChain 是什么", "qa", nil, false)
+	})
+
+	if !strings.Contains(output, "结果: LangChain 是一个框架。") {
+		t.Errorf("output missing chain result:\n%s", output)
+	}
+	if got := len(mock.Requests()); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestRunSimpleModeFallback(t *testing.T) {
+	primary := llmtest.NewMockProvider(nil)
+	primary.On(llmtest.Any()).Fail(llm.ErrUnavailable)
+	backup := llmtest.NewMockProvider(nil)
+	backup.On(llmtest.Any()).Reply("来自备用后端")
+
+	registry := newMockRegistry(primary)
+	registry.Register("mock-backup", backup.Factory())
+	registry.Configure("mock-backup", &llm.Config{Model: "backup-model"})
+	registry.SetFallbacks(llmtest.ModelTypeMock, "mock-backup")
+
+	provider, model, err := registry.ProviderFor("")
+	if err != nil {
+		t.Fatalf("ProviderFor error: %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		runSimpleMode(provider, model, newPromptEngine(t), "你好", "qa", nil, true)
+	})
+
+	for _, want := range []string{"回答: 来自备用后端", "应答后端: mock-backup"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if got := backup.Requests(); len(got) != 1 || got[0].Model != "backup-model" {
+		t.Errorf("backup requests = %+v, want one request for backup-model", got)
+	}
+}
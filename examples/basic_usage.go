@@ -50,7 +50,12 @@ func exampleBasicLLM() {
 		Timeout:     30 * time.Second,
 	}
 
-	provider := llm.NewOpenAIProvider(llmConfig)
+	// 通过注册表按类型创建提供者，换成 llm.ModelTypeClaude 等即可切换后端
+	provider, err := llm.DefaultRegistry.New(llm.ModelTypeOpenAI, llmConfig)
+	if err != nil {
+		log.Printf("Failed to create provider: %v", err)
+		return
+	}
 
 	// 创建聊天请求
 	req := &llm.ChatRequest{
@@ -225,7 +230,11 @@ func exampleCompleteWorkflow() {
 		Timeout:     30 * time.Second,
 	}
 
-	provider := llm.NewOpenAIProvider(llmConfig)
+	provider, err := llm.DefaultRegistry.New(llm.ModelTypeOpenAI, llmConfig)
+	if err != nil {
+		log.Printf("Failed to create provider: %v", err)
+		return
+	}
 	promptEngine := prompt.NewPromptEngine()
 	retriever := rag.NewSimpleRetriever()
 	ragEngine := rag.NewRAGEngine(retriever)
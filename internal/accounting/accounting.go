@@ -0,0 +1,132 @@
+package accounting
+
+import (
+	"sync"
+
+	"go-llm-tools/internal/llm"
+)
+
+// Entry 一次 LLM 调用的用量记录
+type Entry struct {
+	// User 发起调用的用户（或团队），为空时按 anonymous 计
+	User string
+	// Template 使用的 Prompt 模板，为空时不计入模板汇总
+	Template string
+	// Chain 所属的调用链，为空时不计入调用链汇总
+	Chain string
+	// Model 实际应答的模型名，用于查找单价
+	Model string
+	Usage llm.Usage
+}
+
+// Totals 用量与费用汇总
+type Totals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+
+	// UnpricedRequests 模型未配置单价、未计入费用的调用次数
+	UnpricedRequests int `json:"unpriced_requests,omitempty"`
+}
+
+// add 累加一次调用
+func (t *Totals) add(usage llm.Usage, cost float64, priced bool) {
+	t.Requests++
+	t.PromptTokens += usage.PromptTokens
+	t.CompletionTokens += usage.CompletionTokens
+	t.TotalTokens += usage.TotalTokens
+	t.Cost += cost
+	if !priced {
+		t.UnpricedRequests++
+	}
+}
+
+// Summary 按维度分组的用量汇总，费用单位为美元
+type Summary struct {
+	Total     Totals            `json:"total"`
+	Users     map[string]Totals `json:"users"`
+	Templates map[string]Totals `json:"templates"`
+	Chains    map[string]Totals `json:"chains"`
+	Models    map[string]Totals `json:"models"`
+}
+
+// Ledger 按用户、模板、调用链和模型累计用量与费用的账本，可并发使用
+type Ledger struct {
+	mu      sync.Mutex
+	summary Summary
+}
+
+// NewLedger 创建账本
+func NewLedger() *Ledger {
+	l := &Ledger{}
+	l.Reset()
+	return l
+}
+
+// Record 按模型单价计算本次调用的费用并计入账本，返回费用（美元）
+func (l *Ledger) Record(entry Entry) float64 {
+	cost, priced := llm.Cost(entry.Model, entry.Usage)
+
+	user := entry.User
+	if user == "" {
+		user = "anonymous"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.summary.Total.add(entry.Usage, cost, priced)
+	addTo(l.summary.Users, user, entry.Usage, cost, priced)
+	addTo(l.summary.Templates, entry.Template, entry.Usage, cost, priced)
+	addTo(l.summary.Chains, entry.Chain, entry.Usage, cost, priced)
+	addTo(l.summary.Models, entry.Model, entry.Usage, cost, priced)
+
+	return cost
+}
+
+// Summary 返回当前汇总的副本
+func (l *Ledger) Summary() Summary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return Summary{
+		Total:     l.summary.Total,
+		Users:     copyTotals(l.summary.Users),
+		Templates: copyTotals(l.summary.Templates),
+		Chains:    copyTotals(l.summary.Chains),
+		Models:    copyTotals(l.summary.Models),
+	}
+}
+
+// Reset 清空账本
+func (l *Ledger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.summary = Summary{
+		Users:     make(map[string]Totals),
+		Templates: make(map[string]Totals),
+		Chains:    make(map[string]Totals),
+		Models:    make(map[string]Totals),
+	}
+}
+
+// addTo 累加到分组汇总，key 为空时跳过
+func addTo(group map[string]Totals, key string, usage llm.Usage, cost float64, priced bool) {
+	if key == "" {
+		return
+	}
+	totals := group[key]
+	totals.add(usage, cost, priced)
+	group[key] = totals
+}
+
+func copyTotals(group map[string]Totals) map[string]Totals {
+	result := make(map[string]Totals, len(group))
+	for key, totals := range group {
+		result[key] = totals
+	}
+	return result
+}
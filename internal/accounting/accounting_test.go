@@ -0,0 +1,67 @@
+package accounting
+
+import (
+	"math"
+	"testing"
+
+	"go-llm-tools/internal/llm"
+)
+
+func TestLedgerRecord(t *testing.T) {
+	ledger := NewLedger()
+
+	cost := ledger.Record(Entry{
+		User:     "alice",
+		Template: "qa",
+		Chain:    "rag_qa",
+		Model:    "gpt-4o",
+		Usage:    llm.Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100},
+	})
+	if want := 0.0035; math.Abs(cost-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", cost, want)
+	}
+	ledger.Record(Entry{
+		Model: "ernie-4.0-8k",
+		Usage: llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	})
+
+	summary := ledger.Summary()
+	if summary.Total.Requests != 2 || summary.Total.TotalTokens != 1115 || summary.Total.UnpricedRequests != 1 {
+		t.Errorf("total = %+v", summary.Total)
+	}
+	if math.Abs(summary.Total.Cost-cost) > 1e-12 {
+		t.Errorf("total cost = %v, want %v", summary.Total.Cost, cost)
+	}
+	if alice := summary.Users["alice"]; alice.Requests != 1 || alice.PromptTokens != 1000 {
+		t.Errorf("users[alice] = %+v", alice)
+	}
+	if anonymous := summary.Users["anonymous"]; anonymous.Requests != 1 || anonymous.UnpricedRequests != 1 {
+		t.Errorf("users[anonymous] = %+v", anonymous)
+	}
+	// 未指定模板和调用链的调用不计入对应分组
+	if len(summary.Templates) != 1 || summary.Templates["qa"].Requests != 1 {
+		t.Errorf("templates = %+v", summary.Templates)
+	}
+	if len(summary.Chains) != 1 || summary.Chains["rag_qa"].Requests != 1 {
+		t.Errorf("chains = %+v", summary.Chains)
+	}
+	if len(summary.Models) != 2 {
+		t.Errorf("models = %+v", summary.Models)
+	}
+}
+
+func TestLedgerSummaryIsCopy(t *testing.T) {
+	ledger := NewLedger()
+	ledger.Record(Entry{User: "alice", Model: "gpt-4o", Usage: llm.Usage{PromptTokens: 1}})
+
+	summary := ledger.Summary()
+	summary.Users["alice"] = Totals{}
+	if ledger.Summary().Users["alice"].Requests != 1 {
+		t.Error("modifying the summary changed the ledger")
+	}
+
+	ledger.Reset()
+	if summary := ledger.Summary(); summary.Total.Requests != 0 || len(summary.Users) != 0 {
+		t.Errorf("summary after Reset = %+v", summary)
+	}
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// azureDefaultAPIVersion Azure OpenAI 默认 API 版本
+const azureDefaultAPIVersion = "2024-02-01"
+
+// AzureOpenAIProvider Azure OpenAI 提供者
+//
+// 复用 OpenAIProvider 的请求转换逻辑，区别在于：BaseURL 为资源终结点
+// （如 https://my-resource.openai.azure.com），请求按模型名路由到 Deployments 中配置的部署，
+// 并通过 api-key 请求头认证。
+type AzureOpenAIProvider struct {
+	*OpenAIProvider
+}
+
+// NewAzureOpenAIProvider 创建 Azure OpenAI 提供者
+func NewAzureOpenAIProvider(config *Config) *AzureOpenAIProvider {
+	if config == nil {
+		config = &Config{
+			Model:       "gpt-35-turbo",
+			Timeout:     30 * time.Second,
+			MaxRetries:  3,
+			Temperature: 0.7,
+			MaxTokens:   1000,
+			APIVersion:  azureDefaultAPIVersion,
+		}
+	}
+
+	return &AzureOpenAIProvider{
+		OpenAIProvider: &OpenAIProvider{
+			client:       openai.NewClientWithConfig(azureClientConfig(config)),
+			config:       config,
+			clientConfig: azureClientConfig,
+		},
+	}
+}
+
+// GetModelType 获取模型类型
+func (p *AzureOpenAIProvider) GetModelType() ModelType {
+	return ModelTypeAzure
+}
+
+// azureClientConfig 生成 Azure OpenAI 客户端配置
+func azureClientConfig(config *Config) openai.ClientConfig {
+	clientConfig := openai.DefaultAzureConfig(config.APIKey, config.BaseURL)
+	clientConfig.HTTPClient = newHTTPClient()
+	if config.APIVersion != "" {
+		clientConfig.APIVersion = config.APIVersion
+	} else {
+		clientConfig.APIVersion = azureDefaultAPIVersion
+	}
+
+	// 未配置部署的模型沿用 go-openai 的默认规则（去掉模型名中的 "." 和 ":"）
+	defaultMapper := clientConfig.AzureModelMapperFunc
+	deployments := config.Deployments
+	clientConfig.AzureModelMapperFunc = func(model string) string {
+		if deployment, ok := deployments[model]; ok {
+			return deployment
+		}
+		return defaultMapper(model)
+	}
+
+	return clientConfig
+}
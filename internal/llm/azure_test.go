@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureRoutesModelsToDeployments(t *testing.T) {
+	type request struct {
+		path, apiVersion, apiKey string
+	}
+	var got []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, request{path: r.URL.Path, apiVersion: r.URL.Query().Get("api-version"), apiKey: r.Header.Get("api-key")})
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewAzureOpenAIProvider(&Config{
+		APIKey:      "azure-key",
+		BaseURL:     server.URL,
+		Model:       "gpt-4o",
+		Deployments: map[string]string{"gpt-4o": "prod-gpt4o"},
+	})
+
+	tests := []struct {
+		model, path string
+	}{
+		{"gpt-4o", "/openai/deployments/prod-gpt4o/chat/completions"},
+		// 未配置部署时去掉模型名中的 "."
+		{"gpt-3.5-turbo", "/openai/deployments/gpt-35-turbo/chat/completions"},
+	}
+	for _, tt := range tests {
+		got = nil
+		_, err := p.Chat(context.Background(), &ChatRequest{Model: tt.model, Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+		if err != nil {
+			t.Fatalf("Chat(%s) error: %v", tt.model, err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("Chat(%s) sent %d requests, want 1", tt.model, len(got))
+		}
+		want := request{path: tt.path, apiVersion: azureDefaultAPIVersion, apiKey: "azure-key"}
+		if got[0] != want {
+			t.Errorf("Chat(%s) request = %+v, want %+v", tt.model, got[0], want)
+		}
+	}
+
+	if modelType := p.GetModelType(); modelType != ModelTypeAzure {
+		t.Errorf("GetModelType = %s, want %s", modelType, ModelTypeAzure)
+	}
+}
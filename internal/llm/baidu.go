@@ -0,0 +1,480 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	baiduDefaultBaseURL = "https://aip.baidubce.com"
+	baiduChatPath       = "/rpc/2.0/ai_custom/v1/wenxinworkshop/chat/"
+
+	// baiduTokenRefreshMargin access_token 到期前提前刷新的时间
+	baiduTokenRefreshMargin = 5 * time.Minute
+
+	// baiduMinTemperature 千帆允许的最小 temperature，temperature 为 0 时以此近似贪心解码
+	baiduMinTemperature = 0.01
+)
+
+// baiduEndpoints 模型名到千帆接口路径的映射，未列出的模型直接使用模型名作为路径
+var baiduEndpoints = map[string]string{
+	"ernie-4.0-8k":       "completions_pro",
+	"ernie-4.0-turbo-8k": "ernie-4.0-turbo-8k",
+	"ernie-3.5-8k":       "completions",
+	"ernie-speed-8k":     "ernie_speed",
+	"ernie-speed-128k":   "ernie-speed-128k",
+	"ernie-lite-8k":      "ernie-lite-8k",
+	"ernie-tiny-8k":      "ernie-tiny-8k",
+	"ernie-bot":          "completions",
+	"ernie-bot-4":        "completions_pro",
+	"ernie-bot-turbo":    "eb-instant",
+}
+
+// BaiduProvider 百度文心一言（千帆）提供者
+//
+// Config.APIKey 为 API Key（AK），Config.SecretKey 为 Secret Key（SK）。
+// access_token 会被缓存，并在到期前自动刷新。
+type BaiduProvider struct {
+	client *http.Client
+	config *Config
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// baiduError 千帆接口返回的错误，接口出错时 HTTP 状态码仍为 200
+type baiduError struct {
+	Code    int
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *baiduError) Error() string {
+	return fmt.Sprintf("error code: %d, message: %s", e.Code, e.Message)
+}
+
+// kind 按千帆错误码归类，无法归类时返回 nil
+func (e *baiduError) kind() error {
+	switch e.Code {
+	case 6, 110, 111:
+		return ErrAuth
+	case 4, 17, 18, 336501, 336502:
+		return ErrRateLimited
+	case 2, 336100:
+		return ErrUnavailable
+	case 336003:
+		// 输入或输出未通过内容审核
+		return ErrContentFiltered
+	case 336007, 336103:
+		return ErrContextLength
+	default:
+		return nil
+	}
+}
+
+// tokenInvalid access_token 无效或过期
+func (e *baiduError) tokenInvalid() bool {
+	return e.Code == 110 || e.Code == 111
+}
+
+type baiduMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type baiduRequest struct {
+	Messages        []baiduMessage `json:"messages"`
+	System          string         `json:"system,omitempty"`
+	Temperature     float64        `json:"temperature,omitempty"`
+	TopP            float64        `json:"top_p,omitempty"`
+	MaxOutputTokens int            `json:"max_output_tokens,omitempty"`
+	Stop            []string       `json:"stop,omitempty"`
+	Stream          bool           `json:"stream,omitempty"`
+	UserID          string         `json:"user_id,omitempty"`
+}
+
+type baiduResponse struct {
+	ID           string `json:"id"`
+	Object       string `json:"object"`
+	Created      int64  `json:"created"`
+	Result       string `json:"result"`
+	IsEnd        bool   `json:"is_end"`
+	IsTruncated  bool   `json:"is_truncated"`
+	FinishReason string `json:"finish_reason"`
+	Usage        Usage  `json:"usage"`
+	ErrorCode    int    `json:"error_code"`
+	ErrorMsg     string `json:"error_msg"`
+}
+
+// NewBaiduProvider 创建百度文心一言提供者
+func NewBaiduProvider(config *Config) *BaiduProvider {
+	if config == nil {
+		config = &Config{
+			BaseURL:     baiduDefaultBaseURL,
+			Model:       "ernie-3.5-8k",
+			Timeout:     30 * time.Second,
+			MaxRetries:  3,
+			Temperature: 0.7,
+			MaxTokens:   1000,
+		}
+	}
+
+	return &BaiduProvider{
+		client: newHTTPClient(),
+		config: config,
+	}
+}
+
+// Chat 实现聊天接口
+func (p *BaiduProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	baiduReq, err := p.buildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp baiduResponse
+	attempts, err := withRetry(ctx, p.config, func(ctx context.Context) error {
+		return p.withToken(ctx, func(token string) error {
+			resp = baiduResponse{}
+			if err := doJSON(ctx, p.client, http.MethodPost, p.chatURL(req.Model, token), nil, baiduReq, &resp); err != nil {
+				return err
+			}
+			if resp.ErrorCode != 0 {
+				return &baiduError{Code: resp.ErrorCode, Message: resp.ErrorMsg}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("baidu chat completion failed: %w", err)
+	}
+
+	return &ChatResponse{
+		ID:      resp.ID,
+		Object:  "chat.completion",
+		Created: resp.Created,
+		Model:   p.model(req.Model),
+		Choices: []ChatChoice{{
+			Index:        0,
+			Message:      Message{Role: RoleAssistant, Content: resp.Result},
+			FinishReason: baiduFinishReason(resp.FinishReason, resp.IsTruncated),
+		}},
+		Usage:    resp.Usage,
+		Attempts: attempts,
+	}, nil
+}
+
+// ChatStream 实现流式聊天接口
+func (p *BaiduProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	baiduReq, err := p.buildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	baiduReq.Stream = true
+
+	var resp *http.Response
+	_, release, err := withRetryStream(ctx, p.config, func(ctx context.Context) error {
+		return p.withToken(ctx, func(token string) error {
+			var err error
+			resp, err = sendRequest(ctx, p.client, http.MethodPost, p.chatURL(req.Model, token), nil, baiduReq)
+			if err != nil {
+				return err
+			}
+
+			// 出错时千帆直接返回 JSON 而不是事件流
+			if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+				defer resp.Body.Close()
+				var body baiduResponse
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					return fmt.Errorf("failed to decode response: %w", err)
+				}
+				return &baiduError{Code: body.ErrorCode, Message: body.ErrorMsg}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("baidu chat stream failed: %w", err)
+	}
+
+	model := p.model(req.Model)
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+		defer release()
+		defer resp.Body.Close()
+
+		first := true
+		err := readSSE(resp.Body, func(_, data string) bool {
+			var event baiduResponse
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("baidu chat stream failed: %w", err)})
+				return false
+			}
+			if event.ErrorCode != 0 {
+				err := classifyError(&baiduError{Code: event.ErrorCode, Message: event.ErrorMsg})
+				sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("baidu chat stream failed: %w", err)})
+				return false
+			}
+
+			chunk := ChatStreamChunk{
+				ID:      event.ID,
+				Model:   model,
+				Content: event.Result,
+			}
+			if first {
+				chunk.Role = RoleAssistant
+				first = false
+			}
+			if event.IsEnd {
+				chunk.FinishReason = baiduFinishReason(event.FinishReason, event.IsTruncated)
+			}
+			if !sendChunk(ctx, chunks, chunk) {
+				return false
+			}
+
+			if event.IsEnd {
+				usage := event.Usage
+				sendChunk(ctx, chunks, ChatStreamChunk{ID: event.ID, Model: model, Usage: &usage})
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("baidu chat stream failed: %w", classifyError(err))})
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口（千帆对话模型没有独立的补全接口，转换为单轮对话）
+func (p *BaiduProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	chatResp, err := p.Chat(ctx, &ChatRequest{
+		Model:       req.Model,
+		Messages:    []Message{{Role: RoleUser, Content: req.Prompt}},
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	completionResp := &CompletionResponse{
+		ID:       chatResp.ID,
+		Object:   "text_completion",
+		Created:  chatResp.Created,
+		Model:    chatResp.Model,
+		Usage:    chatResp.Usage,
+		Attempts: chatResp.Attempts,
+	}
+	completionResp.Choices = make([]struct {
+		Text         string      `json:"text"`
+		Index        int         `json:"index"`
+		Logprobs     interface{} `json:"logprobs"`
+		FinishReason string      `json:"finish_reason"`
+	}, 1)
+	completionResp.Choices[0].Text = chatResp.Choices[0].Message.Content
+	completionResp.Choices[0].FinishReason = chatResp.Choices[0].FinishReason
+
+	return completionResp, nil
+}
+
+// GetConfig 获取配置
+func (p *BaiduProvider) GetConfig() *Config {
+	return p.config
+}
+
+// SetConfig 设置配置，密钥变化后缓存的 access_token 失效
+func (p *BaiduProvider) SetConfig(config *Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = config
+	p.accessToken = ""
+	p.expiresAt = time.Time{}
+}
+
+// GetModelType 获取模型类型
+func (p *BaiduProvider) GetModelType() ModelType {
+	return ModelTypeBaidu
+}
+
+// withToken 使用 access_token 执行调用，token 失效时刷新后重试一次
+func (p *BaiduProvider) withToken(ctx context.Context, call func(token string) error) error {
+	token, err := p.token(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	err = call(token)
+
+	var baiduErr *baiduError
+	if !errors.As(err, &baiduErr) || !baiduErr.tokenInvalid() {
+		return err
+	}
+
+	token, err = p.token(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	return call(token)
+}
+
+// token 获取 access_token，缓存未过期时直接返回
+func (p *BaiduProvider) token(ctx context.Context, forceRefresh bool) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !forceRefresh && p.accessToken != "" && time.Now().Add(baiduTokenRefreshMargin).Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	query := url.Values{}
+	query.Set("grant_type", "client_credentials")
+	query.Set("client_id", p.config.APIKey)
+	query.Set("client_secret", p.config.SecretKey)
+
+	var resp struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	tokenURL := p.baseURL() + "/oauth/2.0/token?" + query.Encode()
+	if err := doJSON(ctx, p.client, http.MethodPost, tokenURL, nil, nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to get baidu access token: %w", err)
+	}
+	if resp.AccessToken == "" {
+		err := fmt.Errorf("%s %s", resp.Error, resp.ErrorDescription)
+		return "", fmt.Errorf("failed to get baidu access token: %w", &ProviderError{Kind: ErrAuth, Code: resp.Error, Err: err})
+	}
+
+	p.accessToken = resp.AccessToken
+	p.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+
+	return p.accessToken, nil
+}
+
+// buildRequest 将通用聊天请求转换为千帆请求
+func (p *BaiduProvider) buildRequest(req *ChatRequest) (*baiduRequest, error) {
+	if len(req.Tools) > 0 {
+		return nil, fmt.Errorf("%w: baidu provider does not support tools", ErrUnsupportedParameter)
+	}
+	// 千帆的 penalty_score 与 presence / frequency penalty 的含义不同，不做转换
+	if err := checkParams("baidu", req, ParamStop, ParamUser); err != nil {
+		return nil, err
+	}
+
+	baiduReq := &baiduRequest{
+		TopP:            req.TopP,
+		MaxOutputTokens: req.MaxTokens,
+		Stop:            req.Stop,
+		UserID:          req.User,
+	}
+
+	// 千帆的 temperature 取值范围为 (0, 1]，0 映射为允许的最小值而不是省略（省略时使用默认的 0.8）
+	baiduReq.Temperature = math.Min(math.Max(req.Temperature, baiduMinTemperature), 1)
+
+	var system []string
+	for _, msg := range req.Messages {
+		// 千帆不支持多模态，文本类附件拼接到内容中
+		content, err := messageText(msg, "baidu")
+		if err != nil {
+			return nil, err
+		}
+
+		switch msg.Role {
+		case RoleSystem:
+			// system 消息映射为顶层 system 字段
+			system = append(system, content)
+			continue
+		case RoleUser, RoleAssistant:
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+
+		// 千帆要求 user / assistant 交替出现，相邻同角色消息合并为一条
+		if n := len(baiduReq.Messages); n > 0 && baiduReq.Messages[n-1].Role == msg.Role {
+			baiduReq.Messages[n-1].Content += "\n\n" + content
+			continue
+		}
+		baiduReq.Messages = append(baiduReq.Messages, baiduMessage{Role: msg.Role, Content: content})
+	}
+	// 千帆没有通用的结构化输出，在系统提示中说明格式
+	if instruction := responseFormatInstruction(req.ResponseFormat); instruction != "" {
+		system = append(system, instruction)
+	}
+	baiduReq.System = strings.Join(system, "\n\n")
+
+	if len(baiduReq.Messages) == 0 || baiduReq.Messages[0].Role != RoleUser ||
+		baiduReq.Messages[len(baiduReq.Messages)-1].Role != RoleUser {
+		return nil, fmt.Errorf("baidu messages must start and end with a user message")
+	}
+
+	return baiduReq, nil
+}
+
+// chatURL 拼接对话接口地址
+func (p *BaiduProvider) chatURL(model, token string) string {
+	model = p.model(model)
+	endpoint, ok := baiduEndpoints[strings.ToLower(model)]
+	if !ok {
+		endpoint = model
+	}
+	return p.baseURL() + baiduChatPath + endpoint + "?access_token=" + url.QueryEscape(token)
+}
+
+// model 返回实际使用的模型名
+func (p *BaiduProvider) model(model string) string {
+	if model == "" {
+		return p.config.Model
+	}
+	return model
+}
+
+// baseURL 返回接口根地址
+func (p *BaiduProvider) baseURL() string {
+	if p.config.BaseURL == "" {
+		return baiduDefaultBaseURL
+	}
+	return strings.TrimRight(p.config.BaseURL, "/")
+}
+
+// baiduFinishReason 转换千帆的 finish_reason
+func baiduFinishReason(reason string, truncated bool) string {
+	if truncated {
+		return "length"
+	}
+
+	switch reason {
+	case "", "normal", "stop":
+		return "stop"
+	case "function_call":
+		return "tool_calls"
+	default:
+		return reason
+	}
+}
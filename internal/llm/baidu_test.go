@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBaiduBuildRequestTemperature(t *testing.T) {
+	tests := []struct {
+		temperature float64
+		want        float64
+	}{
+		{0, baiduMinTemperature},
+		{-1, baiduMinTemperature},
+		{0.5, 0.5},
+		{1.5, 1},
+	}
+	for _, tt := range tests {
+		req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "你好"}}, Temperature: tt.temperature}
+		baiduReq, err := NewBaiduProvider(nil).buildRequest(req)
+		if err != nil {
+			t.Fatalf("buildRequest error: %v", err)
+		}
+		if baiduReq.Temperature != tt.want {
+			t.Errorf("temperature %v: got %v, want %v", tt.temperature, baiduReq.Temperature, tt.want)
+		}
+	}
+}
+
+func TestBaiduBuildRequestRejectsTools(t *testing.T) {
+	req := &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "你好"}},
+		Tools:    []Tool{{Type: ToolTypeFunction, Function: FunctionDefinition{Name: "search"}}},
+	}
+	if _, err := NewBaiduProvider(nil).buildRequest(req); !errors.Is(err, ErrUnsupportedParameter) {
+		t.Errorf("buildRequest error = %v, want ErrUnsupportedParameter", err)
+	}
+}
+
+func TestBaiduErrorKind(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{110, ErrAuth},
+		{18, ErrRateLimited},
+		{336100, ErrUnavailable},
+		{336003, ErrContentFiltered},
+		{336103, ErrContextLength},
+	}
+	for _, tt := range tests {
+		err := classifyError(&baiduError{Code: tt.code, Message: "error"})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("code %d: got %v, want %v", tt.code, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	breakerDefaultFailureRatio     = 0.5
+	breakerDefaultMinRequests      = 10
+	breakerDefaultWindow           = time.Minute
+	breakerDefaultCooldown         = 30 * time.Second
+	breakerDefaultHalfOpenRequests = 1
+)
+
+// BreakerState 熔断器状态
+type BreakerState string
+
+const (
+	// BreakerClosed 正常放行调用并统计失败比例
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen 熔断中，调用直接失败
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen 冷却结束，放行少量试探调用
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// ErrCircuitOpen 熔断器处于熔断状态，调用未发送到后端
+//
+// 返回的错误同时属于 ErrUnavailable，降级链会切换到下一个后端。
+var ErrCircuitOpen = errors.New("circuit open")
+
+// BreakerConfig 熔断器配置
+type BreakerConfig struct {
+	// FailureRatio 统计窗口内失败比例达到该值时熔断，默认 0.5
+	FailureRatio float64
+
+	// MinRequests 统计窗口内调用次数达到该值才判断失败比例，默认 10
+	MinRequests int
+
+	// Window 统计窗口，每个窗口结束时清零计数，默认 1 分钟
+	Window time.Duration
+
+	// Cooldown 熔断后等待多久进入半开状态，默认 30 秒
+	Cooldown time.Duration
+
+	// HalfOpenRequests 半开状态放行的试探调用数，全部成功后恢复，任一失败则重新熔断，默认 1
+	HalfOpenRequests int
+
+	// IsFailure 判断错误是否计为失败，默认只统计超时和服务不可用；调用方取消的调用不计入
+	IsFailure func(err error) bool
+}
+
+// BreakerStats 熔断器的状态快照
+type BreakerStats struct {
+	State    BreakerState `json:"state"`
+	Requests int          `json:"requests"`
+	Failures int          `json:"failures"`
+
+	// OpenUntil 熔断状态下预计进入半开状态的时间
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// CircuitBreaker 按失败比例熔断的熔断器，可并发使用
+//
+// 关闭状态下统计窗口内的失败比例，达到阈值后熔断，熔断期间调用直接失败；冷却结束后进入半开状态，
+// 放行少量试探调用，全部成功则恢复，任一失败则重新熔断。
+type CircuitBreaker struct {
+	config *BreakerConfig
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	successes   int
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(config *BreakerConfig) *CircuitBreaker {
+	if config == nil {
+		config = &BreakerConfig{}
+	}
+	if config.FailureRatio <= 0 {
+		config.FailureRatio = breakerDefaultFailureRatio
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = breakerDefaultMinRequests
+	}
+	if config.Window <= 0 {
+		config.Window = breakerDefaultWindow
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = breakerDefaultCooldown
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = breakerDefaultHalfOpenRequests
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = isBreakerFailure
+	}
+
+	return &CircuitBreaker{
+		config:      config,
+		state:       BreakerClosed,
+		windowStart: time.Now(),
+	}
+}
+
+// isBreakerFailure 超时和服务不可用说明后端异常，其他错误（如鉴权失败、上下文超长）与后端健康无关
+func isBreakerFailure(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnavailable) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Allow 判断是否放行一次调用
+//
+// 放行时返回的 done 必须在调用结束后以调用的错误执行一次；熔断时返回属于 ErrCircuitOpen 和 ErrUnavailable 的错误。
+func (b *CircuitBreaker) Allow() (func(err error), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.advance(now)
+
+	switch b.state {
+	case BreakerOpen:
+		return nil, &ProviderError{Kind: ErrUnavailable, RetryAfter: b.openedAt.Add(b.config.Cooldown).Sub(now), Err: ErrCircuitOpen}
+	case BreakerHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return nil, &ProviderError{Kind: ErrUnavailable, Err: fmt.Errorf("%w: waiting for probe requests", ErrCircuitOpen)}
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.done(generation, err) })
+	}, nil
+}
+
+// State 返回熔断器当前的状态
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	return b.state
+}
+
+// Stats 返回熔断器的状态快照
+func (b *CircuitBreaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+
+	stats := BreakerStats{State: b.state, Requests: b.requests, Failures: b.failures}
+	if b.state == BreakerOpen {
+		openUntil := b.openedAt.Add(b.config.Cooldown)
+		stats.OpenUntil = &openUntil
+	}
+	return stats
+}
+
+// advance 按时间推进状态：熔断冷却结束后进入半开状态，关闭状态下统计窗口结束时清零计数
+//
+// 窗口结束时同样推进 generation，上一个窗口放行、之后才结束的调用不计入新窗口。
+func (b *CircuitBreaker) advance(now time.Time) {
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) >= b.config.Cooldown {
+			b.transition(BreakerHalfOpen, now)
+		}
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.config.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+			b.generation++
+		}
+	}
+}
+
+// done 记录一次调用的结果，状态已经改变过的调用结果不再计入
+func (b *CircuitBreaker) done(generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	now := time.Now()
+	ignored := errors.Is(err, context.Canceled)
+	failed := err != nil && !ignored && b.config.IsFailure(err)
+
+	switch b.state {
+	case BreakerClosed:
+		if ignored {
+			return
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.config.MinRequests && float64(b.failures)/float64(b.requests) >= b.config.FailureRatio {
+			b.transition(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		b.probes--
+		switch {
+		case ignored:
+		case failed:
+			b.transition(BreakerOpen, now)
+		default:
+			b.successes++
+			if b.successes >= b.config.HalfOpenRequests {
+				b.transition(BreakerClosed, now)
+			}
+		}
+	}
+}
+
+// transition 切换状态并清零计数，之前放行的调用结果不再计入
+func (b *CircuitBreaker) transition(state BreakerState, now time.Time) {
+	b.state = state
+	b.generation++
+	b.requests, b.failures, b.probes, b.successes = 0, 0, 0, 0
+	b.windowStart = now
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+}
+
+// BreakerProvider 调用前经过熔断器的提供者
+//
+// 熔断期间调用直接返回属于 ErrCircuitOpen 和 ErrUnavailable 的错误，不会等待后端超时；
+// 放在降级链中时会切换到下一个后端。流式调用在流结束后按是否出错记录结果。
+type BreakerProvider struct {
+	Provider
+
+	breaker *CircuitBreaker
+}
+
+// NewBreakerProvider 创建带熔断的提供者
+func NewBreakerProvider(provider Provider, breaker *CircuitBreaker) *BreakerProvider {
+	if breaker == nil {
+		breaker = NewCircuitBreaker(nil)
+	}
+	return &BreakerProvider{Provider: provider, breaker: breaker}
+}
+
+// withBreaker 配置了熔断器时用 BreakerProvider 包装提供者
+func withBreaker(provider Provider, config *Config) Provider {
+	if config == nil || config.Breaker == nil {
+		return provider
+	}
+	return NewBreakerProvider(provider, config.Breaker)
+}
+
+// Chat 实现聊天接口
+func (p *BreakerProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	done, err := p.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.Provider.Chat(ctx, req)
+	done(err)
+	return resp, err
+}
+
+// ChatStream 实现流式聊天接口
+func (p *BreakerProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	done, err := p.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := p.Provider.ChatStream(ctx, req)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+
+		var streamErr error
+		for chunk := range stream {
+			if chunk.Err != nil {
+				streamErr = chunk.Err
+			}
+			if !sendChunk(ctx, chunks, chunk) {
+				done(ctx.Err())
+				return
+			}
+		}
+		done(streamErr)
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口
+func (p *BreakerProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	done, err := p.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.Provider.Complete(ctx, req)
+	done(err)
+	return resp, err
+}
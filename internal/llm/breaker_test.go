@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var errBackendDown = fmt.Errorf("backend down: %w", ErrUnavailable)
+
+// allowDone 放行一次调用并立即以 err 结束
+func allowDone(t *testing.T, breaker *CircuitBreaker, err error) {
+	t.Helper()
+	done, allowErr := breaker.Allow()
+	if allowErr != nil {
+		t.Fatalf("Allow error: %v", allowErr)
+	}
+	done(err)
+}
+
+func TestBreakerOpensOnFailureRatio(t *testing.T) {
+	breaker := NewCircuitBreaker(&BreakerConfig{FailureRatio: 0.5, MinRequests: 4, Window: time.Hour, Cooldown: time.Hour})
+
+	allowDone(t, breaker, nil)
+	allowDone(t, breaker, errBackendDown)
+	allowDone(t, breaker, nil)
+	if state := breaker.State(); state != BreakerClosed {
+		t.Fatalf("state after 3 requests = %s, want closed", state)
+	}
+
+	allowDone(t, breaker, errBackendDown)
+	if state := breaker.State(); state != BreakerOpen {
+		t.Fatalf("state after 2/4 failures = %s, want open", state)
+	}
+
+	_, err := breaker.Allow()
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Allow while open error = %v, want ErrCircuitOpen and ErrUnavailable", err)
+	}
+}
+
+func TestBreakerIgnoresUnrelatedErrors(t *testing.T) {
+	breaker := NewCircuitBreaker(&BreakerConfig{MinRequests: 2, Window: time.Hour})
+
+	allowDone(t, breaker, fmt.Errorf("bad key: %w", ErrAuth))
+	allowDone(t, breaker, fmt.Errorf("too long: %w", ErrContextLength))
+	if stats := breaker.Stats(); stats.State != BreakerClosed || stats.Failures != 0 {
+		t.Errorf("stats = %+v, want closed with no failures", stats)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	newOpen := func(t *testing.T) *CircuitBreaker {
+		breaker := NewCircuitBreaker(&BreakerConfig{MinRequests: 1, Window: time.Hour, Cooldown: 20 * time.Millisecond})
+		allowDone(t, breaker, errBackendDown)
+		time.Sleep(30 * time.Millisecond)
+		if state := breaker.State(); state != BreakerHalfOpen {
+			t.Fatalf("state after cooldown = %s, want half_open", state)
+		}
+		return breaker
+	}
+
+	t.Run("probe succeeds", func(t *testing.T) {
+		breaker := newOpen(t)
+		done, err := breaker.Allow()
+		if err != nil {
+			t.Fatalf("probe Allow error: %v", err)
+		}
+		if _, err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("second Allow while probing error = %v, want ErrCircuitOpen", err)
+		}
+		done(nil)
+		if state := breaker.State(); state != BreakerClosed {
+			t.Errorf("state after successful probe = %s, want closed", state)
+		}
+	})
+
+	t.Run("probe fails", func(t *testing.T) {
+		breaker := newOpen(t)
+		allowDone(t, breaker, errBackendDown)
+		if state := breaker.State(); state != BreakerOpen {
+			t.Errorf("state after failed probe = %s, want open", state)
+		}
+	})
+}
+
+func TestBreakerIgnoresResultsFromPreviousWindow(t *testing.T) {
+	breaker := NewCircuitBreaker(&BreakerConfig{MinRequests: 1, Window: 20 * time.Millisecond, Cooldown: time.Hour})
+
+	done, err := breaker.Allow()
+	if err != nil {
+		t.Fatalf("Allow error: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	breaker.State() // 开始新的统计窗口
+
+	done(errBackendDown)
+	if stats := breaker.Stats(); stats.State != BreakerClosed || stats.Requests != 0 {
+		t.Errorf("stats = %+v, want closed with the stale result ignored", stats)
+	}
+}
@@ -0,0 +1,366 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// CacheConfig 响应缓存配置
+type CacheConfig struct {
+	// Store 缓存存储，为空时使用默认容量和过期时间的内存缓存
+	Store CacheStore
+
+	// Force 温度大于 0 时也使用缓存；默认只缓存温度为 0（结果确定）的请求
+	Force bool
+}
+
+// CachedProvider 按请求精确匹配缓存响应的提供者
+//
+// 缓存键为后端、模型、消息、temperature、top_p、max_tokens、工具定义、响应格式和其他采样参数的规范化哈希。
+// 命中缓存时响应的 Cached 为 true、Usage 为 0（没有产生新的用量），流式调用会按缓存内容回放。
+type CachedProvider struct {
+	Provider
+
+	config *CacheConfig
+}
+
+// NewCachedProvider 创建带响应缓存的提供者
+func NewCachedProvider(provider Provider, config *CacheConfig) *CachedProvider {
+	if config == nil {
+		config = &CacheConfig{}
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryCache(cacheDefaultCapacity, cacheDefaultTTL)
+	}
+
+	return &CachedProvider{Provider: provider, config: config}
+}
+
+// withCache 配置了缓存存储时用 CachedProvider 包装提供者
+func withCache(provider Provider, config *Config) Provider {
+	if config == nil || config.Cache == nil {
+		return provider
+	}
+
+	return NewCachedProvider(provider, &CacheConfig{
+		Store: config.Cache,
+		Force: config.CacheForce,
+	})
+}
+
+// Chat 实现聊天接口
+func (p *CachedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	key, ok := p.chatKey(req)
+	if !ok {
+		return p.Provider.Chat(ctx, req)
+	}
+
+	var cached ChatResponse
+	if p.load(key, &cached) {
+		cached.Usage, cached.Attempts, cached.Cached = Usage{}, 0, true
+		return &cached, nil
+	}
+
+	resp, err := p.Provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	p.save(key, resp)
+	return resp, nil
+}
+
+// ChatStream 实现流式聊天接口
+//
+// 命中缓存时按 choice 回放完整内容；未命中时转发上游数据块，流正常结束后写入缓存（含工具调用的流不缓存）。
+func (p *CachedProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	key, ok := p.chatKey(req)
+	if !ok {
+		return p.Provider.ChatStream(ctx, req)
+	}
+
+	var cached ChatResponse
+	if p.load(key, &cached) {
+		return replayStream(&cached), nil
+	}
+
+	stream, err := p.Provider.ChatStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+
+		collector := newStreamCollector()
+		for chunk := range stream {
+			collector.add(chunk)
+			if !sendChunk(ctx, chunks, chunk) {
+				return
+			}
+		}
+
+		if resp, ok := collector.response(); ok {
+			p.save(key, resp)
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口
+func (p *CachedProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	if !p.cacheable(req.Temperature) {
+		return p.Provider.Complete(ctx, req)
+	}
+	key, ok := cacheKey(struct {
+		Kind        string    `json:"kind"`
+		Backend     ModelType `json:"backend"`
+		Model       string    `json:"model"`
+		Prompt      string    `json:"prompt"`
+		Temperature float64   `json:"temperature"`
+		TopP        float64   `json:"top_p"`
+		MaxTokens   int       `json:"max_tokens"`
+		Stop        []string  `json:"stop"`
+	}{
+		Kind:        "completion",
+		Backend:     p.GetModelType(),
+		Model:       p.model(req.Model),
+		Prompt:      req.Prompt,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+	})
+	if !ok {
+		return p.Provider.Complete(ctx, req)
+	}
+
+	var cached CompletionResponse
+	if p.load(key, &cached) {
+		cached.Usage, cached.Attempts, cached.Cached = Usage{}, 0, true
+		return &cached, nil
+	}
+
+	resp, err := p.Provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	p.save(key, resp)
+	return resp, nil
+}
+
+// chatKey 计算聊天请求的缓存键，请求不可缓存时返回 false
+func (p *CachedProvider) chatKey(req *ChatRequest) (string, bool) {
+	if !p.cacheable(req.Temperature) {
+		return "", false
+	}
+
+	return cacheKey(struct {
+		Kind           string          `json:"kind"`
+		Backend        ModelType       `json:"backend"`
+		Model          string          `json:"model"`
+		Messages       []Message       `json:"messages"`
+		Temperature    float64         `json:"temperature"`
+		TopP           float64         `json:"top_p"`
+		MaxTokens      int             `json:"max_tokens"`
+		Tools          []Tool          `json:"tools"`
+		ToolChoice     string          `json:"tool_choice"`
+		ResponseFormat *ResponseFormat `json:"response_format"`
+
+		// user 不影响生成结果，不计入缓存键
+		Stop             []string       `json:"stop"`
+		N                int            `json:"n"`
+		Seed             *int           `json:"seed"`
+		PresencePenalty  float64        `json:"presence_penalty"`
+		FrequencyPenalty float64        `json:"frequency_penalty"`
+		LogitBias        map[string]int `json:"logit_bias"`
+		LogProbs         bool           `json:"logprobs"`
+		TopLogProbs      int            `json:"top_logprobs"`
+	}{
+		Kind:           "chat",
+		Backend:        p.GetModelType(),
+		Model:          p.model(req.Model),
+		Messages:       req.Messages,
+		Temperature:    req.Temperature,
+		TopP:           req.TopP,
+		MaxTokens:      req.MaxTokens,
+		Tools:          req.Tools,
+		ToolChoice:     req.ToolChoice,
+		ResponseFormat: req.ResponseFormat,
+
+		Stop:             req.Stop,
+		N:                req.N,
+		Seed:             req.Seed,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		LogitBias:        req.LogitBias,
+		LogProbs:         req.LogProbs,
+		TopLogProbs:      req.TopLogProbs,
+	})
+}
+
+// cacheable 温度大于 0 时结果不确定，除非强制缓存否则不使用缓存
+func (p *CachedProvider) cacheable(temperature float64) bool {
+	return p.config.Force || temperature <= 0
+}
+
+// model 返回实际使用的模型名，请求未指定时使用配置中的模型
+func (p *CachedProvider) model(model string) string {
+	if model == "" {
+		if config := p.GetConfig(); config != nil {
+			return config.Model
+		}
+	}
+	return model
+}
+
+// load 读取并反序列化缓存值
+func (p *CachedProvider) load(key string, value interface{}) bool {
+	data, ok := p.config.Store.Get(key)
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(data, value) == nil
+}
+
+// save 序列化并写入缓存，写入失败不影响本次调用
+func (p *CachedProvider) save(key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	_ = p.config.Store.Set(key, data)
+}
+
+// cacheKey 返回数据规范化 JSON 的 SHA-256 哈希（结构体字段顺序固定，map 按键排序）
+func cacheKey(data interface{}) (string, bool) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// replayStream 将缓存的响应按 choice 转换为流式数据块
+func replayStream(resp *ChatResponse) <-chan ChatStreamChunk {
+	chunks := make(chan ChatStreamChunk, len(resp.Choices))
+	for _, choice := range resp.Choices {
+		var toolCalls []ToolCall
+		for i, call := range choice.Message.ToolCalls {
+			index := i
+			call.Index = &index
+			toolCalls = append(toolCalls, call)
+		}
+
+		chunks <- ChatStreamChunk{
+			ID:           resp.ID,
+			Model:        resp.Model,
+			Index:        choice.Index,
+			Role:         choice.Message.Role,
+			Content:      choice.Message.Content,
+			ToolCalls:    toolCalls,
+			FinishReason: choice.FinishReason,
+			Provider:     resp.Provider,
+
+			SystemFingerprint: resp.SystemFingerprint,
+		}
+	}
+	close(chunks)
+	return chunks
+}
+
+// streamCollector 将流式数据块拼接为完整响应
+type streamCollector struct {
+	resp    *ChatResponse
+	choices map[int]*ChatChoice
+
+	// incomplete 流出错或包含工具调用，不能作为完整响应使用
+	incomplete bool
+}
+
+func newStreamCollector() *streamCollector {
+	return &streamCollector{
+		resp:    &ChatResponse{Object: "chat.completion"},
+		choices: make(map[int]*ChatChoice),
+	}
+}
+
+// add 拼接一个数据块
+func (c *streamCollector) add(chunk ChatStreamChunk) {
+	if chunk.Err != nil || len(chunk.ToolCalls) > 0 {
+		c.incomplete = true
+		return
+	}
+
+	if chunk.ID != "" {
+		c.resp.ID = chunk.ID
+	}
+	if chunk.Model != "" {
+		c.resp.Model = chunk.Model
+	}
+	if chunk.Provider != "" {
+		c.resp.Provider = chunk.Provider
+	}
+	if chunk.SystemFingerprint != "" {
+		c.resp.SystemFingerprint = chunk.SystemFingerprint
+	}
+	if chunk.Usage != nil {
+		c.resp.Usage = *chunk.Usage
+		return
+	}
+
+	choice, ok := c.choices[chunk.Index]
+	if !ok {
+		choice = &ChatChoice{Index: chunk.Index, Message: Message{Role: RoleAssistant}}
+		c.choices[chunk.Index] = choice
+	}
+	if chunk.Role != "" {
+		choice.Message.Role = chunk.Role
+	}
+	choice.Message.Content += chunk.Content
+	if chunk.FinishReason != "" {
+		choice.FinishReason = chunk.FinishReason
+	}
+}
+
+// response 返回拼接后的响应，流未正常结束（出错、含工具调用或有 choice 没有结束原因）时返回 false
+func (c *streamCollector) response() (*ChatResponse, bool) {
+	if c.incomplete || len(c.choices) == 0 {
+		return nil, false
+	}
+
+	indexes := make([]int, 0, len(c.choices))
+	for index, choice := range c.choices {
+		if choice.FinishReason == "" {
+			return nil, false
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		c.resp.Choices = append(c.resp.Choices, *c.choices[index])
+	}
+	return c.resp, true
+}
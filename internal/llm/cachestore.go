@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheStore 响应缓存的存储，键为请求的规范化哈希，值为序列化后的响应
+type CacheStore interface {
+	// Get 获取未过期的缓存值
+	Get(key string) ([]byte, bool)
+
+	// Set 写入缓存值
+	Set(key string, value []byte) error
+}
+
+const (
+	cacheDefaultCapacity = 1000
+	cacheDefaultTTL      = time.Hour
+)
+
+// memoryEntry 内存缓存的条目
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryCache 带过期时间的内存 LRU 缓存
+type MemoryCache struct {
+	capacity int
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// NewMemoryCache 创建内存缓存，最多保存 capacity 个条目，ttl 为 0 时永不过期
+func NewMemoryCache(capacity int, ttl time.Duration) *MemoryCache {
+	if capacity <= 0 {
+		capacity = cacheDefaultCapacity
+	}
+
+	return &MemoryCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get 获取缓存值，命中时移到最近使用的位置
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set 写入缓存值，超出容量时淘汰最久未使用的条目
+func (c *MemoryCache) Set(key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		c.order.MoveToFront(element)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// Len 返回当前条目数（含尚未清理的过期条目）
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove 删除条目，调用方需持有锁
+func (c *MemoryCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*memoryEntry).key)
+}
+
+// DiskCache 以文件形式保存在目录中的缓存，进程重启后仍然有效
+//
+// 每个键对应一个文件，按文件修改时间判断是否过期；目录在首次写入时创建。
+type DiskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewDiskCache 创建磁盘缓存，ttl 为 0 时永不过期
+func NewDiskCache(dir string, ttl time.Duration) *DiskCache {
+	return &DiskCache{dir: dir, ttl: ttl}
+}
+
+// Get 获取缓存值，过期的文件会被删除
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if c.ttl > 0 && time.Since(info.ModTime()) > c.ttl {
+		os.Remove(path)
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set 写入缓存值，先写临时文件再重命名，避免并发读到不完整的内容
+func (c *DiskCache) Set(key string, value []byte) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	file, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(value); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return nil
+}
+
+// path 返回键对应的文件路径
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
@@ -0,0 +1,555 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	claudeDefaultBaseURL   = "https://api.anthropic.com/v1"
+	claudeAPIVersion       = "2023-06-01"
+	claudeDefaultMaxTokens = 1024
+)
+
+// ClaudeProvider Anthropic Claude 提供者（Messages API）
+type ClaudeProvider struct {
+	client *http.Client
+	config *Config
+}
+
+// claudeRequest Messages API 请求
+type claudeRequest struct {
+	Model         string          `json:"model"`
+	System        string          `json:"system,omitempty"`
+	Messages      []claudeMessage `json:"messages"`
+	MaxTokens     int             `json:"max_tokens"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	TopP          *float64        `json:"top_p,omitempty"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+	Tools         []claudeTool    `json:"tools,omitempty"`
+	ToolChoice    *claudeChoice   `json:"tool_choice,omitempty"`
+	Metadata      *claudeMetadata `json:"metadata,omitempty"`
+}
+
+type claudeMetadata struct {
+	UserID string `json:"user_id"`
+}
+
+type claudeMessage struct {
+	Role    string        `json:"role"`
+	Content []claudeBlock `json:"content"`
+}
+
+// claudeBlock 内容块，按 Type 区分 text / tool_use / tool_result
+type claudeBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	Source    *claudeSource   `json:"source,omitempty"`
+}
+
+// claudeSource 图片和文档内容块的数据来源
+type claudeSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type claudeTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+type claudeChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type claudeUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// claudeResponse Messages API 响应
+type claudeResponse struct {
+	ID         string        `json:"id"`
+	Type       string        `json:"type"`
+	Role       string        `json:"role"`
+	Model      string        `json:"model"`
+	Content    []claudeBlock `json:"content"`
+	StopReason string        `json:"stop_reason"`
+	Usage      claudeUsage   `json:"usage"`
+}
+
+// claudeStreamEvent 流式事件，按 Type 区分
+type claudeStreamEvent struct {
+	Type         string          `json:"type"`
+	Index        int             `json:"index"`
+	Message      *claudeResponse `json:"message"`
+	ContentBlock *claudeBlock    `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *claudeUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewClaudeProvider 创建 Claude 提供者
+func NewClaudeProvider(config *Config) *ClaudeProvider {
+	if config == nil {
+		config = &Config{
+			BaseURL:     claudeDefaultBaseURL,
+			Model:       "claude-3-haiku-20240307",
+			Timeout:     30 * time.Second,
+			MaxRetries:  3,
+			Temperature: 0.7,
+			MaxTokens:   1000,
+		}
+	}
+
+	return &ClaudeProvider{
+		client: newHTTPClient(),
+		config: config,
+	}
+}
+
+// Chat 实现聊天接口
+func (p *ClaudeProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	claudeReq, err := p.buildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp claudeResponse
+	attempts, err := withRetry(ctx, p.config, func(ctx context.Context) error {
+		return doJSON(ctx, p.client, http.MethodPost, p.url("/messages"), p.header(), claudeReq, &resp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claude chat completion failed: %w", err)
+	}
+
+	message := Message{Role: RoleAssistant}
+	var text strings.Builder
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				ID:   block.ID,
+				Type: ToolTypeFunction,
+				Function: FunctionCall{
+					Name:      block.Name,
+					Arguments: string(block.Input),
+				},
+			})
+		}
+	}
+	message.Content = text.String()
+
+	return &ChatResponse{
+		ID:      resp.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   resp.Model,
+		Choices: []ChatChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: claudeFinishReason(resp.StopReason),
+		}},
+		Usage: Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+		Attempts: attempts,
+	}, nil
+}
+
+// ChatStream 实现流式聊天接口
+func (p *ClaudeProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	claudeReq, err := p.buildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	claudeReq.Stream = true
+
+	var resp *http.Response
+	_, release, err := withRetryStream(ctx, p.config, func(ctx context.Context) error {
+		var err error
+		resp, err = sendRequest(ctx, p.client, http.MethodPost, p.url("/messages"), p.header(), claudeReq)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claude chat stream failed: %w", err)
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+		defer release()
+		defer resp.Body.Close()
+
+		var (
+			id, model string
+			usage     Usage
+			// 内容块下标到工具调用下标的映射
+			toolIndex = make(map[int]int)
+			// 收到 message_stop 或错误事件，流正常结束
+			finished bool
+		)
+
+		err := readSSE(resp.Body, func(_, data string) bool {
+			var event claudeStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				finished = true
+				sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("claude chat stream failed: %w", err)})
+				return false
+			}
+
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil {
+					id, model = event.Message.ID, event.Message.Model
+					usage.PromptTokens = event.Message.Usage.InputTokens
+					return sendChunk(ctx, chunks, ChatStreamChunk{ID: id, Model: model, Role: RoleAssistant})
+				}
+			case "content_block_start":
+				if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+					index := len(toolIndex)
+					toolIndex[event.Index] = index
+					return sendChunk(ctx, chunks, ChatStreamChunk{
+						ID:    id,
+						Model: model,
+						ToolCalls: []ToolCall{{
+							Index:    &index,
+							ID:       event.ContentBlock.ID,
+							Type:     ToolTypeFunction,
+							Function: FunctionCall{Name: event.ContentBlock.Name},
+						}},
+					})
+				}
+			case "content_block_delta":
+				switch event.Delta.Type {
+				case "text_delta":
+					return sendChunk(ctx, chunks, ChatStreamChunk{ID: id, Model: model, Content: event.Delta.Text})
+				case "input_json_delta":
+					index := toolIndex[event.Index]
+					return sendChunk(ctx, chunks, ChatStreamChunk{
+						ID:    id,
+						Model: model,
+						ToolCalls: []ToolCall{{
+							Index:    &index,
+							Function: FunctionCall{Arguments: event.Delta.PartialJSON},
+						}},
+					})
+				}
+			case "message_delta":
+				if event.Usage != nil {
+					usage.CompletionTokens = event.Usage.OutputTokens
+				}
+				if event.Delta.StopReason != "" {
+					return sendChunk(ctx, chunks, ChatStreamChunk{
+						ID:           id,
+						Model:        model,
+						FinishReason: claudeFinishReason(event.Delta.StopReason),
+					})
+				}
+			case "message_stop":
+				finished = true
+				usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+				sendChunk(ctx, chunks, ChatStreamChunk{ID: id, Model: model, Usage: &usage})
+				return false
+			case "error":
+				finished = true
+				code, message := "", "unknown error"
+				if event.Error != nil {
+					code, message = event.Error.Type, event.Error.Message
+				}
+				sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("claude chat stream failed: %w", streamError(code, message))})
+				return false
+			}
+
+			return true
+		})
+		// 连接在 message_stop 之前断开时回答不完整，按服务不可用返回以便重试
+		if err == nil && !finished && ctx.Err() == nil {
+			err = fmt.Errorf("stream ended before message_stop: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("claude chat stream failed: %w", classifyError(err))})
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口（Claude 没有独立的补全接口，转换为单轮对话）
+func (p *ClaudeProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	claudeReq, err := p.buildRequest(&ChatRequest{
+		Model:       req.Model,
+		Messages:    []Message{{Role: RoleUser, Content: req.Prompt}},
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+	})
+	if err != nil {
+		return nil, err
+	}
+	claudeReq.StopSequences = req.Stop
+
+	var resp claudeResponse
+	attempts, err := withRetry(ctx, p.config, func(ctx context.Context) error {
+		return doJSON(ctx, p.client, http.MethodPost, p.url("/messages"), p.header(), claudeReq, &resp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claude completion failed: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	completionResp := &CompletionResponse{
+		ID:      resp.ID,
+		Object:  "text_completion",
+		Created: time.Now().Unix(),
+		Model:   resp.Model,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+		Attempts: attempts,
+	}
+	completionResp.Choices = make([]struct {
+		Text         string      `json:"text"`
+		Index        int         `json:"index"`
+		Logprobs     interface{} `json:"logprobs"`
+		FinishReason string      `json:"finish_reason"`
+	}, 1)
+	completionResp.Choices[0].Text = text.String()
+	completionResp.Choices[0].FinishReason = claudeFinishReason(resp.StopReason)
+
+	return completionResp, nil
+}
+
+// GetConfig 获取配置
+func (p *ClaudeProvider) GetConfig() *Config {
+	return p.config
+}
+
+// SetConfig 设置配置
+func (p *ClaudeProvider) SetConfig(config *Config) {
+	p.config = config
+}
+
+// GetModelType 获取模型类型
+func (p *ClaudeProvider) GetModelType() ModelType {
+	return ModelTypeClaude
+}
+
+// buildRequest 将通用聊天请求转换为 Messages API 请求
+func (p *ClaudeProvider) buildRequest(req *ChatRequest) (*claudeRequest, error) {
+	if err := checkParams("claude", req, ParamStop, ParamUser); err != nil {
+		return nil, err
+	}
+
+	claudeReq := &claudeRequest{
+		Model:         req.Model,
+		MaxTokens:     req.MaxTokens,
+		StopSequences: req.Stop,
+	}
+	if req.User != "" {
+		claudeReq.Metadata = &claudeMetadata{UserID: req.User}
+	}
+	if claudeReq.Model == "" {
+		claudeReq.Model = p.config.Model
+	}
+
+	// max_tokens 是必填字段
+	if claudeReq.MaxTokens <= 0 {
+		claudeReq.MaxTokens = p.config.MaxTokens
+	}
+	if claudeReq.MaxTokens <= 0 {
+		claudeReq.MaxTokens = claudeDefaultMaxTokens
+	}
+
+	// Claude 的 temperature 取值范围为 [0, 1]，0 也是有效值，总是发送截断后的值
+	temperature := math.Min(math.Max(req.Temperature, 0), 1)
+	claudeReq.Temperature = &temperature
+	if req.TopP > 0 {
+		topP := req.TopP
+		claudeReq.TopP = &topP
+	}
+
+	var system []string
+	for _, msg := range req.Messages {
+		var (
+			role   string
+			blocks []claudeBlock
+		)
+
+		switch msg.Role {
+		case RoleSystem:
+			// system 消息映射为顶层 system 字段
+			text, err := messageText(msg, "claude")
+			if err != nil {
+				return nil, err
+			}
+			system = append(system, text)
+			continue
+		case RoleTool:
+			// 工具结果以 user 角色的 tool_result 内容块回传
+			role = RoleUser
+			blocks = []claudeBlock{{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}}
+		case RoleUser, RoleAssistant:
+			role = msg.Role
+			if msg.Content != "" {
+				blocks = append(blocks, claudeBlock{Type: "text", Text: msg.Content})
+			}
+			for _, part := range msg.Parts {
+				block, err := toClaudeBlock(part)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, block)
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				if !json.Valid(input) {
+					return nil, fmt.Errorf("invalid arguments for tool call %s", call.ID)
+				}
+				blocks = append(blocks, claudeBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+
+		// Claude 要求 user / assistant 交替出现，相邻同角色消息合并为一条
+		if n := len(claudeReq.Messages); n > 0 && claudeReq.Messages[n-1].Role == role {
+			claudeReq.Messages[n-1].Content = append(claudeReq.Messages[n-1].Content, blocks...)
+			continue
+		}
+		claudeReq.Messages = append(claudeReq.Messages, claudeMessage{Role: role, Content: blocks})
+	}
+	// Claude 没有原生的结构化输出，在系统提示中说明格式
+	if instruction := responseFormatInstruction(req.ResponseFormat); instruction != "" {
+		system = append(system, instruction)
+	}
+	claudeReq.System = strings.Join(system, "\n\n")
+
+	// 转换工具定义，tool_choice 为 none 时不发送工具
+	if req.ToolChoice != ToolChoiceNone {
+		for _, tool := range req.Tools {
+			schema := tool.Function.Parameters
+			if schema == nil {
+				schema = map[string]interface{}{"type": "object"}
+			}
+			claudeReq.Tools = append(claudeReq.Tools, claudeTool{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				InputSchema: schema,
+			})
+		}
+
+		switch req.ToolChoice {
+		case "", ToolChoiceAuto:
+		case ToolChoiceRequired:
+			claudeReq.ToolChoice = &claudeChoice{Type: "any"}
+		default:
+			claudeReq.ToolChoice = &claudeChoice{Type: "tool", Name: req.ToolChoice}
+		}
+	}
+
+	return claudeReq, nil
+}
+
+// toClaudeBlock 将内容片段转换为内容块，图片转换为 image 块，PDF 转换为 document 块
+func toClaudeBlock(part ContentPart) (claudeBlock, error) {
+	switch part.Type {
+	case ContentPartImage:
+		if mediaType, data, ok := part.imageData(); ok {
+			return claudeBlock{Type: "image", Source: &claudeSource{Type: "base64", MediaType: mediaType, Data: data}}, nil
+		}
+		return claudeBlock{Type: "image", Source: &claudeSource{Type: "url", URL: part.URL}}, nil
+	case ContentPartFile:
+		if part.Data != "" && part.MIMEType == "application/pdf" {
+			return claudeBlock{Type: "document", Source: &claudeSource{Type: "base64", MediaType: part.MIMEType, Data: part.Data}}, nil
+		}
+	}
+
+	text, ok := part.inlineText()
+	if !ok {
+		return claudeBlock{}, unsupportedPart("claude", part)
+	}
+	return claudeBlock{Type: "text", Text: text}, nil
+}
+
+// url 拼接接口地址
+func (p *ClaudeProvider) url(path string) string {
+	baseURL := p.config.BaseURL
+	if baseURL == "" {
+		baseURL = claudeDefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + path
+}
+
+// header 构建认证请求头
+func (p *ClaudeProvider) header() http.Header {
+	header := http.Header{}
+	header.Set("x-api-key", p.config.APIKey)
+	header.Set("anthropic-version", claudeAPIVersion)
+	return header
+}
+
+// claudeFinishReason 将 Claude 的 stop_reason 转换为 OpenAI 风格的 finish_reason
+func claudeFinishReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return reason
+	}
+}
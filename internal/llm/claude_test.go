@@ -0,0 +1,275 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// claudeServer 启动模拟 Messages API 的测试服务，收到的请求体写入 got
+func claudeServer(t *testing.T, status int, header http.Header, body string, got *map[string]interface{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("path = %s, want /messages", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != claudeAPIVersion {
+			t.Errorf("headers = %v, want api key and version", r.Header)
+		}
+		if got != nil {
+			data, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(data, got); err != nil {
+				t.Errorf("invalid request body %s: %v", data, err)
+			}
+		}
+
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClaude(baseURL string, maxTokens int) *ClaudeProvider {
+	return NewClaudeProvider(&Config{
+		APIKey:    "test-key",
+		BaseURL:   baseURL,
+		Model:     "claude-3-haiku-20240307",
+		Timeout:   5 * time.Second,
+		MaxTokens: maxTokens,
+	})
+}
+
+const claudeTextResponse = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-haiku-20240307",
+	"content":[{"type":"text","text":"Hello"}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":5}}`
+
+func TestClaudeChatRequest(t *testing.T) {
+	var got map[string]interface{}
+	server := claudeServer(t, http.StatusOK, nil, claudeTextResponse, &got)
+
+	_, err := newTestClaude(server.URL, 0).Chat(context.Background(), &ChatRequest{
+		Messages: []Message{
+			{Role: RoleSystem, Content: "You are terse."},
+			{Role: RoleUser, Content: "Hi"},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+
+	if got["system"] != "You are terse." {
+		t.Errorf("system = %v, want top-level system prompt", got["system"])
+	}
+	messages, _ := got["messages"].([]interface{})
+	if len(messages) != 1 || messages[0].(map[string]interface{})["role"] != RoleUser {
+		t.Errorf("messages = %v, want only the user message", messages)
+	}
+	if got["model"] != "claude-3-haiku-20240307" {
+		t.Errorf("model = %v, want config model", got["model"])
+	}
+	if got["max_tokens"] != float64(claudeDefaultMaxTokens) {
+		t.Errorf("max_tokens = %v, want default %d", got["max_tokens"], claudeDefaultMaxTokens)
+	}
+	if temperature, ok := got["temperature"]; !ok || temperature != float64(0) {
+		t.Errorf("temperature = %v (sent %v), want explicit 0", temperature, ok)
+	}
+}
+
+func TestClaudeBuildRequestDefaults(t *testing.T) {
+	tests := []struct {
+		name            string
+		configMaxTokens int
+		req             ChatRequest
+		wantMaxTokens   int
+		wantTemperature float64
+	}{
+		{"request max tokens", 500, ChatRequest{MaxTokens: 200, Temperature: 0.5}, 200, 0.5},
+		{"config max tokens", 500, ChatRequest{Temperature: 0.3}, 500, 0.3},
+		{"default max tokens", 0, ChatRequest{}, claudeDefaultMaxTokens, 0},
+		{"temperature clamped", 0, ChatRequest{Temperature: 1.5}, claudeDefaultMaxTokens, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Messages = []Message{{Role: RoleUser, Content: "Hi"}}
+			claudeReq, err := newTestClaude("", tt.configMaxTokens).buildRequest(&tt.req)
+			if err != nil {
+				t.Fatalf("buildRequest error: %v", err)
+			}
+			if claudeReq.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("MaxTokens = %d, want %d", claudeReq.MaxTokens, tt.wantMaxTokens)
+			}
+			if claudeReq.Temperature == nil || *claudeReq.Temperature != tt.wantTemperature {
+				t.Errorf("Temperature = %v, want %v", claudeReq.Temperature, tt.wantTemperature)
+			}
+		})
+	}
+}
+
+func TestClaudeChatResponse(t *testing.T) {
+	tests := []struct {
+		stopReason string
+		want       string
+	}{
+		{"end_turn", "stop"},
+		{"stop_sequence", "stop"},
+		{"max_tokens", "length"},
+		{"tool_use", "tool_calls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stopReason, func(t *testing.T) {
+			body := `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-haiku-20240307",
+				"content":[{"type":"text","text":"Checking. "},{"type":"tool_use","id":"toolu_1","name":"lookup","input":{"q":"go"}}],
+				"stop_reason":"` + tt.stopReason + `","usage":{"input_tokens":12,"output_tokens":5}}`
+			server := claudeServer(t, http.StatusOK, nil, body, nil)
+
+			resp, err := newTestClaude(server.URL, 0).Chat(context.Background(), &ChatRequest{
+				Messages: []Message{{Role: RoleUser, Content: "Hi"}},
+			})
+			if err != nil {
+				t.Fatalf("Chat error: %v", err)
+			}
+
+			choice := resp.Choices[0]
+			if choice.FinishReason != tt.want {
+				t.Errorf("FinishReason = %q, want %q", choice.FinishReason, tt.want)
+			}
+			if choice.Message.Content != "Checking. " {
+				t.Errorf("Content = %q", choice.Message.Content)
+			}
+			if len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].Function.Name != "lookup" || choice.Message.ToolCalls[0].Function.Arguments != `{"q":"go"}` {
+				t.Errorf("ToolCalls = %+v", choice.Message.ToolCalls)
+			}
+			if want := (Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}); resp.Usage != want {
+				t.Errorf("Usage = %+v, want %+v", resp.Usage, want)
+			}
+		})
+	}
+}
+
+func TestClaudeChatErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		header         http.Header
+		body           string
+		want           error
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:           "rate limited",
+			status:         http.StatusTooManyRequests,
+			header:         http.Header{"Retry-After": []string{"7"}},
+			body:           `{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`,
+			want:           ErrRateLimited,
+			wantRetryAfter: 7 * time.Second,
+		},
+		{
+			name:   "auth",
+			status: http.StatusUnauthorized,
+			body:   `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`,
+			want:   ErrAuth,
+		},
+		{
+			name:   "context length",
+			status: http.StatusBadRequest,
+			body:   `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			want:   ErrContextLength,
+		},
+		{
+			name:   "overloaded",
+			status: 529,
+			body:   `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			want:   ErrUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := claudeServer(t, tt.status, tt.header, tt.body, nil)
+
+			_, err := newTestClaude(server.URL, 0).Chat(context.Background(), &ChatRequest{
+				Messages: []Message{{Role: RoleUser, Content: "Hi"}},
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Chat error = %v, want %v", err, tt.want)
+			}
+			var providerErr *ProviderError
+			if !errors.As(err, &providerErr) || providerErr.StatusCode != tt.status {
+				t.Errorf("ProviderError = %+v, want status %d", providerErr, tt.status)
+			}
+			if got := RetryAfter(err); got != tt.wantRetryAfter {
+				t.Errorf("RetryAfter = %v, want %v", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestClaudeChatStream(t *testing.T) {
+	events := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude-3-haiku-20240307\",\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"lo\"}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"max_tokens\"},\"usage\":{\"output_tokens\":5}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	server := claudeServer(t, http.StatusOK, nil, events, nil)
+
+	stream, err := newTestClaude(server.URL, 0).ChatStream(context.Background(), &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "Hi"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream error: %v", err)
+	}
+
+	var (
+		content, finish string
+		usage           *Usage
+	)
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("chunk error: %v", chunk.Err)
+		}
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if content != "Hello" || finish != "length" {
+		t.Errorf("content = %q, finish = %q", content, finish)
+	}
+	if usage == nil || *usage != (Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestClaudeChatStreamTruncated(t *testing.T) {
+	events := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude-3-haiku-20240307\",\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n"
+	server := claudeServer(t, http.StatusOK, nil, events, nil)
+
+	stream, err := newTestClaude(server.URL, 0).ChatStream(context.Background(), &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "Hi"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream error: %v", err)
+	}
+
+	var last ChatStreamChunk
+	for chunk := range stream {
+		last = chunk
+	}
+	if !errors.Is(last.Err, io.ErrUnexpectedEOF) || !errors.Is(last.Err, ErrUnavailable) || !IsRetryable(last.Err) {
+		t.Errorf("last chunk error = %v, want retryable unexpected EOF", last.Err)
+	}
+}
@@ -0,0 +1,213 @@
+package llm
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 内容片段类型
+const (
+	ContentPartText  = "text"
+	ContentPartImage = "image"
+	ContentPartFile  = "file"
+)
+
+// maxAttachmentSize 从本地文件读取附件的大小上限
+const maxAttachmentSize = 20 << 20
+
+// imageTokens 图片及无法按文本统计的文件，按 OpenAI 高精度模式下 1024x1024 图片的 Token 数估算
+const imageTokens = 765
+
+// ErrUnsupportedContent 后端不支持消息中的图片或文件
+var ErrUnsupportedContent = errors.New("unsupported message content")
+
+// ContentPart 多模态消息的内容片段
+//
+// 图片通过 URL（http(s) 地址或 data URL）或 Data（base64 编码，需填写 MIMEType）提供；
+// 文件通过 FileID（已上传到后端的文件）或 Data 提供，文本类文件在不支持文件的后端会作为文本发送。
+type ContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+
+	// Detail 图片的解析精度：low、high 或 auto，仅 OpenAI 使用
+	Detail string `json:"detail,omitempty"`
+}
+
+// TextPart 创建文本片段
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+// ImageURLPart 创建图片地址片段，url 可以是 http(s) 地址或 data URL
+func ImageURLPart(url string) ContentPart {
+	return ContentPart{Type: ContentPartImage, URL: url}
+}
+
+// ImageDataPart 创建内嵌图片数据的片段
+func ImageDataPart(mimeType string, data []byte) ContentPart {
+	return ContentPart{Type: ContentPartImage, MIMEType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}
+}
+
+// FileRefPart 创建引用已上传文件的片段
+func FileRefPart(fileID string) ContentPart {
+	return ContentPart{Type: ContentPartFile, FileID: fileID}
+}
+
+// PartFromFile 读取本地文件作为附件，图片生成图片片段，其他文件生成文件片段
+func PartFromFile(path string) (ContentPart, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if info.Size() > maxAttachmentSize {
+		return ContentPart{}, fmt.Errorf("attachment %s is larger than %d MB", path, maxAttachmentSize>>20)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(path)))
+	if mimeType == "" {
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+
+	if strings.HasPrefix(mimeType, "image/") {
+		return ImageDataPart(mimeType, data), nil
+	}
+	return ContentPart{
+		Type:     ContentPartFile,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		MIMEType: mimeType,
+		Filename: filepath.Base(path),
+	}, nil
+}
+
+// Validate 检查片段的字段是否完整
+func (p ContentPart) Validate() error {
+	switch p.Type {
+	case ContentPartText:
+		return nil
+	case ContentPartImage:
+		if p.URL == "" && p.Data == "" {
+			return fmt.Errorf("image part requires url or data")
+		}
+	case ContentPartFile:
+		if p.FileID == "" && p.Data == "" {
+			return fmt.Errorf("file part requires file_id or data")
+		}
+	default:
+		return fmt.Errorf("unknown content part type: %s", p.Type)
+	}
+
+	if p.Data != "" {
+		if p.MIMEType == "" {
+			return fmt.Errorf("%s part with data requires mime_type", p.Type)
+		}
+		if _, err := base64.StdEncoding.DecodeString(p.Data); err != nil {
+			return fmt.Errorf("%s part data is not valid base64", p.Type)
+		}
+	}
+	return nil
+}
+
+// dataURL 返回图片的地址，内嵌数据转换为 data URL
+func (p ContentPart) dataURL() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	return "data:" + p.MIMEType + ";base64," + p.Data
+}
+
+// imageData 返回图片的 MIME 类型和 base64 数据，http(s) 地址返回 false
+func (p ContentPart) imageData() (string, string, bool) {
+	if p.Data != "" {
+		return p.MIMEType, p.Data, true
+	}
+
+	// data:image/png;base64,....
+	header, data, ok := strings.Cut(strings.TrimPrefix(p.URL, "data:"), ",")
+	if !ok || !strings.HasPrefix(p.URL, "data:") || !strings.HasSuffix(header, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(header, ";base64"), data, true
+}
+
+// inlineText 文本片段和文本类文件可以作为纯文本发送，返回其文本
+func (p ContentPart) inlineText() (string, bool) {
+	switch p.Type {
+	case ContentPartText:
+		return p.Text, true
+	case ContentPartFile:
+		if p.Data == "" || !isTextMIME(p.MIMEType) {
+			return "", false
+		}
+		data, err := base64.StdEncoding.DecodeString(p.Data)
+		if err != nil {
+			return "", false
+		}
+		name := p.Filename
+		if name == "" {
+			name = "附件"
+		}
+		return fmt.Sprintf("文件 %s：\n%s", name, data), true
+	default:
+		return "", false
+	}
+}
+
+// isTextMIME 判断 MIME 类型是否为文本
+func isTextMIME(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "text/"),
+		mimeType == "application/json",
+		mimeType == "application/xml",
+		mimeType == "application/x-yaml",
+		mimeType == "application/yaml":
+		return true
+	default:
+		return false
+	}
+}
+
+// messageText 将消息内容和片段拼接为纯文本，供不支持多模态的后端使用
+//
+// 包含图片或无法作为文本发送的文件时返回 ErrUnsupportedContent。
+func messageText(msg Message, provider string) (string, error) {
+	texts := make([]string, 0, len(msg.Parts)+1)
+	if msg.Content != "" {
+		texts = append(texts, msg.Content)
+	}
+	for _, part := range msg.Parts {
+		text, ok := part.inlineText()
+		if !ok {
+			return "", unsupportedPart(provider, part)
+		}
+		texts = append(texts, text)
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// unsupportedPart 返回后端不支持该片段的错误
+func unsupportedPart(provider string, part ContentPart) error {
+	switch {
+	case part.Type == ContentPartImage:
+		return fmt.Errorf("%w: %s provider does not support images", ErrUnsupportedContent, provider)
+	case part.FileID != "":
+		return fmt.Errorf("%w: %s provider does not support file references", ErrUnsupportedContent, provider)
+	default:
+		return fmt.Errorf("%w: %s provider does not support %s files", ErrUnsupportedContent, provider, part.MIMEType)
+	}
+}
@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// textFilePart 创建内嵌文本文件的片段
+func textFilePart(name, mimeType, text string) ContentPart {
+	return ContentPart{
+		Type:     ContentPartFile,
+		Data:     base64.StdEncoding.EncodeToString([]byte(text)),
+		MIMEType: mimeType,
+		Filename: name,
+	}
+}
+
+func TestContentPartValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		part    ContentPart
+		problem string
+	}{
+		{"text", TextPart("hi"), ""},
+		{"image url", ImageURLPart("https://example.com/a.png"), ""},
+		{"image data", ImageDataPart("image/png", []byte{1, 2}), ""},
+		{"file ref", FileRefPart("file-1"), ""},
+		{"empty image", ContentPart{Type: ContentPartImage}, "requires url or data"},
+		{"empty file", ContentPart{Type: ContentPartFile}, "requires file_id or data"},
+		{"missing mime", ContentPart{Type: ContentPartImage, Data: "AAAA"}, "requires mime_type"},
+		{"bad base64", ContentPart{Type: ContentPartImage, Data: "!!", MIMEType: "image/png"}, "not valid base64"},
+		{"unknown type", ContentPart{Type: "audio"}, "unknown content part type"},
+	}
+	for _, tt := range tests {
+		err := tt.part.Validate()
+		if tt.problem == "" {
+			if err != nil {
+				t.Errorf("%s: Validate error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.problem) {
+			t.Errorf("%s: Validate error = %v, want %q", tt.name, err, tt.problem)
+		}
+	}
+}
+
+func TestPartFromFile(t *testing.T) {
+	dir := t.TempDir()
+	png := filepath.Join(dir, "chart.png")
+	notes := filepath.Join(dir, "notes.txt")
+	os.WriteFile(png, []byte("\x89PNG\r\n\x1a\n"), 0o644)
+	os.WriteFile(notes, []byte("meeting notes"), 0o644)
+
+	image, err := PartFromFile(png)
+	if err != nil {
+		t.Fatalf("PartFromFile error: %v", err)
+	}
+	if image.Type != ContentPartImage || image.MIMEType != "image/png" {
+		t.Errorf("image part = %+v", image)
+	}
+
+	file, err := PartFromFile(notes)
+	if err != nil {
+		t.Fatalf("PartFromFile error: %v", err)
+	}
+	if file.Type != ContentPartFile || file.MIMEType != "text/plain" || file.Filename != "notes.txt" {
+		t.Errorf("file part = %+v", file)
+	}
+
+	if _, err := PartFromFile(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("PartFromFile on a missing file returned nil error")
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	msg := Message{
+		Role:    RoleUser,
+		Content: "总结附件",
+		Parts:   []ContentPart{textFilePart("notes.txt", "text/plain", "meeting notes")},
+	}
+	text, err := messageText(msg, "baidu")
+	if err != nil {
+		t.Fatalf("messageText error: %v", err)
+	}
+	if text != "总结附件\n\n文件 notes.txt：\nmeeting notes" {
+		t.Errorf("messageText = %q", text)
+	}
+
+	tests := []struct {
+		part    ContentPart
+		problem string
+	}{
+		{ImageURLPart("https://example.com/a.png"), "does not support images"},
+		{FileRefPart("file-1"), "does not support file references"},
+		{textFilePart("a.pdf", "application/pdf", "%PDF"), "does not support application/pdf files"},
+	}
+	for _, tt := range tests {
+		_, err := messageText(Message{Role: RoleUser, Parts: []ContentPart{tt.part}}, "baidu")
+		if !errors.Is(err, ErrUnsupportedContent) || !strings.Contains(err.Error(), tt.problem) {
+			t.Errorf("messageText(%+v) error = %v, want ErrUnsupportedContent %q", tt.part, err, tt.problem)
+		}
+	}
+}
+
+func TestToOpenAIMessageParts(t *testing.T) {
+	msg := Message{
+		Role:    RoleUser,
+		Content: "描述图片",
+		Parts: []ContentPart{
+			ImageDataPart("image/png", []byte{1, 2, 3}),
+			textFilePart("notes.txt", "text/plain", "meeting notes"),
+		},
+	}
+	message, err := toOpenAIMessage(msg)
+	if err != nil {
+		t.Fatalf("toOpenAIMessage error: %v", err)
+	}
+	if message.Content != "" || len(message.MultiContent) != 3 {
+		t.Fatalf("message = %+v, want only MultiContent with 3 parts", message)
+	}
+	if part := message.MultiContent[0]; part.Type != openai.ChatMessagePartTypeText || part.Text != "描述图片" {
+		t.Errorf("part 0 = %+v, want the message content", part)
+	}
+	if part := message.MultiContent[1]; part.ImageURL == nil || part.ImageURL.URL != "data:image/png;base64,AQID" {
+		t.Errorf("part 1 = %+v, want a data URL", part)
+	}
+	if part := message.MultiContent[2]; part.Type != openai.ChatMessagePartTypeText || !strings.Contains(part.Text, "meeting notes") {
+		t.Errorf("part 2 = %+v, want the inlined file", part)
+	}
+
+	if _, err := toOpenAIMessage(Message{Role: RoleUser, Parts: []ContentPart{FileRefPart("file-1")}}); !errors.Is(err, ErrUnsupportedContent) {
+		t.Errorf("file reference error = %v, want ErrUnsupportedContent", err)
+	}
+}
+
+func TestToClaudeBlock(t *testing.T) {
+	tests := []struct {
+		name       string
+		part       ContentPart
+		blockType  string
+		sourceType string
+	}{
+		{"image data", ImageDataPart("image/png", []byte{1}), "image", "base64"},
+		{"image data url", ImageURLPart("data:image/jpeg;base64,AQ=="), "image", "base64"},
+		{"image url", ImageURLPart("https://example.com/a.png"), "image", "url"},
+		{"pdf", textFilePart("a.pdf", "application/pdf", "%PDF"), "document", "base64"},
+		{"text file", textFilePart("notes.txt", "text/plain", "notes"), "text", ""},
+	}
+	for _, tt := range tests {
+		block, err := toClaudeBlock(tt.part)
+		if err != nil {
+			t.Errorf("%s: toClaudeBlock error: %v", tt.name, err)
+			continue
+		}
+		sourceType := ""
+		if block.Source != nil {
+			sourceType = block.Source.Type
+		}
+		if block.Type != tt.blockType || sourceType != tt.sourceType {
+			t.Errorf("%s: block = %s/%s, want %s/%s", tt.name, block.Type, sourceType, tt.blockType, tt.sourceType)
+		}
+	}
+
+	if _, err := toClaudeBlock(FileRefPart("file-1")); !errors.Is(err, ErrUnsupportedContent) {
+		t.Errorf("file reference error = %v, want ErrUnsupportedContent", err)
+	}
+}
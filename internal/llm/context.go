@@ -0,0 +1,207 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// trimMarker 裁剪消息内容时插入的省略标记
+const trimMarker = "\n...\n"
+
+var (
+	contextWindowsMu sync.RWMutex
+
+	// contextWindows 模型名前缀到上下文长度（Token 数）的映射，按最长前缀匹配
+	contextWindows = map[string]int{
+		"gpt-4o":                 128000,
+		"gpt-4.1":                1047576,
+		"gpt-4-turbo":            128000,
+		"gpt-4-1106":             128000,
+		"gpt-4-0125":             128000,
+		"gpt-4-32k":              32768,
+		"gpt-4":                  8192,
+		"gpt-3.5-turbo":          16385,
+		"gpt-3.5-turbo-instruct": 4096,
+		"chatgpt-4o":             128000,
+		"o1":                     200000,
+		"o1-mini":                128000,
+		"o3":                     200000,
+		"claude-":                200000,
+		"ernie-4.0-8k":           8192,
+		"ernie-3.5-8k":           8192,
+		"ernie-3.5-128k":         128000,
+		"ernie-speed-8k":         8192,
+		"ernie-speed-128k":       128000,
+		"ernie-lite-8k":          8192,
+		"qwen2":                  32768,
+		"llama3":                 8192,
+	}
+)
+
+// ContextWindow 返回模型的上下文长度，未知模型返回 0
+func ContextWindow(model string) int {
+	contextWindowsMu.RLock()
+	defer contextWindowsMu.RUnlock()
+	return contextWindows[longestPrefix(contextWindows, model)]
+}
+
+// SetContextWindow 设置或覆盖某个模型名前缀的上下文长度
+func SetContextWindow(prefix string, tokens int) {
+	contextWindowsMu.Lock()
+	defer contextWindowsMu.Unlock()
+	contextWindows[strings.ToLower(prefix)] = tokens
+}
+
+// FitMessages 将消息裁剪到 budget 个 Token 以内
+//
+// 系统消息和最后一条消息总会保留；最后一条消息是工具结果时，末尾连续的工具结果和发起调用的助手消息作为整体保留。
+// 先从最早的消息开始整条丢弃（带工具调用的助手消息与其工具结果一起丢弃），仍然超出时从中间裁剪保留部分的文本
+// （内容和文本片段，从最长的开始）。系统消息本身已超出预算时返回 ErrContextLength。
+func FitMessages(tokenizer Tokenizer, messages []Message, budget int) ([]Message, error) {
+	if CountMessageTokens(tokenizer, messages) <= budget {
+		return messages, nil
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("%w: budget %d is too small", ErrContextLength, budget)
+	}
+
+	// 只丢弃最后一条系统消息之后、末尾保留部分之前的消息
+	tail := trailingUnit(messages)
+	first := 0
+	for i, message := range messages[:tail] {
+		if message.Role == RoleSystem {
+			first = i + 1
+		}
+	}
+
+	kept := append([]Message(nil), messages...)
+	for first < tail && CountMessageTokens(tokenizer, kept) > budget {
+		// 工具结果不能脱离对应的工具调用单独存在，随之一起丢弃
+		end := first + 1
+		for end < tail && kept[end].Role == RoleTool {
+			end++
+		}
+
+		kept = append(kept[:first], kept[end:]...)
+		tail -= end - first
+	}
+
+	total := CountMessageTokens(tokenizer, kept)
+	if total <= budget {
+		return kept, nil
+	}
+
+	if reduced := trimMessages(tokenizer, kept[tail:], total-budget); reduced < total-budget {
+		return nil, fmt.Errorf("%w: %d tokens required, budget is %d", ErrContextLength, total-reduced, budget)
+	}
+	return kept, nil
+}
+
+// trailingUnit 返回末尾必须整体保留的消息的起始下标
+//
+// 通常只有最后一条消息；最后一条是工具结果时，包括末尾连续的工具结果和之前发起工具调用的助手消息。
+func trailingUnit(messages []Message) int {
+	tail := len(messages) - 1
+	if messages[tail].Role != RoleTool {
+		return tail
+	}
+
+	for tail > 0 && messages[tail-1].Role == RoleTool {
+		tail--
+	}
+	if tail > 0 && messages[tail-1].Role == RoleAssistant && len(messages[tail-1].ToolCalls) > 0 {
+		tail--
+	}
+	return tail
+}
+
+// trimMessages 从中间裁剪消息的内容和文本片段，最多减少 overflow 个 Token，返回实际减少的 Token 数
+//
+// 从最长的文本开始裁剪，每段文本至少保留省略标记之外的一个 Token；图片等无法裁剪的片段保持不变。
+// 被裁剪的文本文件片段会转换为文本片段。messages 中的片段切片会被复制，不影响调用方的消息。
+func trimMessages(tokenizer Tokenizer, messages []Message, overflow int) int {
+	type piece struct {
+		message, part int // part 为 -1 表示消息内容
+		text          string
+		tokens        int
+	}
+
+	var pieces []piece
+	for i, message := range messages {
+		if message.Content != "" {
+			pieces = append(pieces, piece{message: i, part: -1, text: message.Content, tokens: tokenizer.Count(message.Content)})
+		}
+		for j, part := range message.Parts {
+			if text, ok := part.inlineText(); ok && text != "" {
+				pieces = append(pieces, piece{message: i, part: j, text: text, tokens: tokenizer.Count(text)})
+			}
+		}
+	}
+	sort.SliceStable(pieces, func(i, j int) bool { return pieces[i].tokens > pieces[j].tokens })
+
+	marker := tokenizer.Count(trimMarker)
+	reduced := 0
+	for _, p := range pieces {
+		if reduced >= overflow {
+			break
+		}
+
+		limit := p.tokens - (overflow - reduced)
+		if limit <= marker {
+			limit = marker + 1
+		}
+		if limit >= p.tokens {
+			continue
+		}
+
+		text := trimMiddle(tokenizer, p.text, limit)
+		reduced += p.tokens - tokenizer.Count(text)
+
+		message := &messages[p.message]
+		if p.part < 0 {
+			message.Content = text
+			continue
+		}
+		message.Parts = append([]ContentPart(nil), message.Parts...)
+		message.Parts[p.part] = TextPart(text)
+	}
+	return reduced
+}
+
+// FitChatRequest 按模型的上下文长度裁剪请求中的消息，并为输出预留 req.MaxTokens 个 Token
+//
+// 上下文长度未知的模型不做处理。
+func FitChatRequest(req *ChatRequest) error {
+	window := ContextWindow(req.Model)
+	if window <= 0 {
+		return nil
+	}
+
+	messages, err := FitMessages(TokenizerForModel(req.Model), req.Messages, window-req.MaxTokens)
+	if err != nil {
+		return err
+	}
+
+	req.Messages = messages
+	return nil
+}
+
+// trimMiddle 保留文本首尾，从中间裁剪到 limit 个 Token 以内
+func trimMiddle(tokenizer Tokenizer, text string, limit int) string {
+	runes := []rune(text)
+
+	// 二分查找首尾各保留的最多字符数
+	low, high := 0, len(runes)/2
+	for low < high {
+		mid := (low + high + 1) / 2
+		if tokenizer.Count(string(runes[:mid])+trimMarker+string(runes[len(runes)-mid:])) <= limit {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	return string(runes[:low]) + trimMarker + string(runes[len(runes)-low:])
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFitMessagesDropsOldest(t *testing.T) {
+	tokenizer := CharTokenizer{}
+	messages := []Message{
+		{Role: RoleSystem, Content: "系统提示"},
+		{Role: RoleUser, Content: strings.Repeat("旧", 100)},
+		{Role: RoleAssistant, Content: strings.Repeat("答", 100)},
+		{Role: RoleUser, Content: "新问题"},
+	}
+	budget := CountMessageTokens(tokenizer, []Message{messages[0], messages[2], messages[3]})
+
+	kept, err := FitMessages(tokenizer, messages, budget)
+	if err != nil {
+		t.Fatalf("FitMessages error: %v", err)
+	}
+	if len(kept) != 3 || kept[0].Role != RoleSystem || kept[1].Role != RoleAssistant || kept[2].Content != "新问题" {
+		t.Errorf("kept = %+v, want system, assistant and last user message", kept)
+	}
+	if len(messages) != 4 || messages[1].Content != strings.Repeat("旧", 100) {
+		t.Error("FitMessages modified the caller's messages")
+	}
+}
+
+func TestFitMessagesKeepsTrailingToolUnit(t *testing.T) {
+	tokenizer := CharTokenizer{}
+	call := Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_1", Type: ToolTypeFunction, Function: FunctionCall{Name: "lookup", Arguments: `{"q":"go"}`}}}}
+	messages := []Message{
+		{Role: RoleUser, Content: strings.Repeat("旧", 100)},
+		{Role: RoleUser, Content: "查一下"},
+		call,
+		{Role: RoleTool, ToolCallID: "call_1", Content: strings.Repeat("果", 50)},
+	}
+	// 预算只够工具调用和工具结果
+	budget := CountMessageTokens(tokenizer, messages[2:])
+
+	kept, err := FitMessages(tokenizer, messages, budget)
+	if err != nil {
+		t.Fatalf("FitMessages error: %v", err)
+	}
+	if len(kept) != 2 || len(kept[0].ToolCalls) != 1 || kept[1].Role != RoleTool {
+		t.Fatalf("kept = %+v, want assistant tool call followed by its result", kept)
+	}
+
+	// 预算不够时裁剪工具结果，而不是丢弃工具调用
+	kept, err = FitMessages(tokenizer, messages, budget-20)
+	if err != nil {
+		t.Fatalf("FitMessages error: %v", err)
+	}
+	if len(kept) != 2 || len(kept[0].ToolCalls) != 1 || !strings.Contains(kept[1].Content, trimMarker) {
+		t.Errorf("kept = %+v, want trimmed tool result after its tool call", kept)
+	}
+	if CountMessageTokens(tokenizer, kept) > budget-20 {
+		t.Errorf("kept tokens = %d, want at most %d", CountMessageTokens(tokenizer, kept), budget-20)
+	}
+}
+
+func TestFitMessagesTrimsParts(t *testing.T) {
+	tokenizer := CharTokenizer{}
+	image := ImageURLPart("https://example.com/a.png")
+	messages := []Message{{
+		Role:  RoleUser,
+		Parts: []ContentPart{TextPart(strings.Repeat("文", 200)), image},
+	}}
+	budget := CountMessageTokens(tokenizer, messages) - 100
+
+	kept, err := FitMessages(tokenizer, messages, budget)
+	if err != nil {
+		t.Fatalf("FitMessages error: %v", err)
+	}
+	if got := CountMessageTokens(tokenizer, kept); got > budget {
+		t.Errorf("kept tokens = %d, want at most %d", got, budget)
+	}
+	if !strings.Contains(kept[0].Parts[0].Text, trimMarker) || kept[0].Parts[1] != image {
+		t.Errorf("parts = %+v, want trimmed text and untouched image", kept[0].Parts)
+	}
+	if messages[0].Parts[0].Text != strings.Repeat("文", 200) {
+		t.Error("FitMessages modified the caller's parts")
+	}
+}
+
+func TestFitMessagesTooSmall(t *testing.T) {
+	tokenizer := CharTokenizer{}
+	messages := []Message{
+		{Role: RoleSystem, Content: strings.Repeat("系", 100)},
+		{Role: RoleUser, Parts: []ContentPart{ImageURLPart("https://example.com/a.png")}},
+	}
+
+	if _, err := FitMessages(tokenizer, messages, 200); !errors.Is(err, ErrContextLength) {
+		t.Errorf("FitMessages error = %v, want ErrContextLength", err)
+	}
+}
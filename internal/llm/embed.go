@@ -0,0 +1,280 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Embedder 向量化接口
+type Embedder interface {
+	// Embed 批量计算文本的向量，返回值与 texts 一一对应
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+
+	// Dimensions 向量维度，未知时（如尚未调用过的本地模型）返回 0
+	Dimensions() int
+}
+
+// EmbedFuncOf 将 Embedder 转换为单条文本的 EmbedFunc，用于语义缓存等场景
+func EmbedFuncOf(embedder Embedder) EmbedFunc {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		vectors, err := embedder.Embed(ctx, []string{text})
+		if err != nil {
+			return nil, err
+		}
+		return vectors[0], nil
+	}
+}
+
+const (
+	openAIEmbedMaxBatchSize   = 2048
+	openAIEmbedMaxBatchTokens = 300000
+	localEmbedMaxBatchSize    = 64
+	localEmbedMaxBatchTokens  = 32768
+)
+
+// embeddingDimensions 已知向量化模型的默认维度
+var embeddingDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+	"nomic-embed-text":       768,
+	"mxbai-embed-large":      1024,
+	"bge-m3":                 1024,
+}
+
+// embedBatches 按条数和 Token 数上限切分文本，返回各批次的 [起, 止) 下标
+//
+// 单条文本超过 Token 上限时单独成批，由上游返回上下文长度错误。
+func embedBatches(tokenizer Tokenizer, texts []string, maxSize, maxTokens int) [][2]int {
+	var (
+		batches [][2]int
+		start   int
+		tokens  int
+	)
+	for i, text := range texts {
+		count := tokenizer.Count(text)
+		if i > start && (i-start >= maxSize || (maxTokens > 0 && tokens+count > maxTokens)) {
+			batches = append(batches, [2]int{start, i})
+			start, tokens = i, 0
+		}
+		tokens += count
+	}
+	if start < len(texts) {
+		batches = append(batches, [2]int{start, len(texts)})
+	}
+	return batches
+}
+
+// embedInBatches 分批调用 call 并按原顺序拼接结果
+func embedInBatches(ctx context.Context, model string, texts []string, maxSize, maxTokens int, call func(ctx context.Context, batch []string) ([][]float32, error)) ([][]float32, error) {
+	if maxSize <= 0 {
+		maxSize = len(texts)
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for _, batch := range embedBatches(TokenizerForModel(model), texts, maxSize, maxTokens) {
+		result, err := call(ctx, texts[batch[0]:batch[1]])
+		if err != nil {
+			return nil, err
+		}
+		if len(result) != batch[1]-batch[0] {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", batch[1]-batch[0], len(result))
+		}
+		vectors = append(vectors, result...)
+	}
+	return vectors, nil
+}
+
+// dimensionsOf 记录并返回向量维度
+type dimensionsOf struct {
+	mu    sync.RWMutex
+	value int
+}
+
+func (d *dimensionsOf) get() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.value
+}
+
+func (d *dimensionsOf) observe(vectors [][]float32) {
+	if len(vectors) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.value = len(vectors[0])
+}
+
+// OpenAIEmbedder OpenAI 向量化，config.Model 为向量化模型（如 text-embedding-3-small）
+type OpenAIEmbedder struct {
+	client *openai.Client
+	config *Config
+
+	// MaxBatchSize 单次请求最多的文本数，默认 2048
+	MaxBatchSize int
+	// MaxBatchTokens 单次请求最多的 Token 数，默认 300000
+	MaxBatchTokens int
+
+	dimensions dimensionsOf
+}
+
+// NewOpenAIEmbedder 创建 OpenAI 向量化
+func NewOpenAIEmbedder(config *Config) *OpenAIEmbedder {
+	if config == nil {
+		config = &Config{
+			BaseURL:    "https://api.openai.com/v1",
+			Model:      "text-embedding-3-small",
+			Timeout:    30 * time.Second,
+			MaxRetries: 3,
+		}
+	}
+
+	e := &OpenAIEmbedder{
+		client:         openai.NewClientWithConfig(openAIClientConfig(config)),
+		config:         config,
+		MaxBatchSize:   openAIEmbedMaxBatchSize,
+		MaxBatchTokens: openAIEmbedMaxBatchTokens,
+	}
+	e.dimensions.value = config.Dimensions
+	if e.dimensions.value <= 0 {
+		e.dimensions.value = embeddingDimensions[longestPrefix(embeddingDimensions, config.Model)]
+	}
+	return e
+}
+
+// Embed 实现向量化接口
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	vectors, err := embedInBatches(ctx, e.config.Model, texts, e.MaxBatchSize, e.MaxBatchTokens, func(ctx context.Context, batch []string) ([][]float32, error) {
+		req := openai.EmbeddingRequestStrings{
+			Input:      batch,
+			Model:      openai.EmbeddingModel(e.config.Model),
+			Dimensions: e.config.Dimensions,
+		}
+
+		var resp openai.EmbeddingResponse
+		_, err := withRetry(ctx, e.config, func(ctx context.Context) error {
+			var err error
+			resp, err = e.client.CreateEmbeddings(ctx, req)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// 按 index 还原输入顺序
+		sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+		result := make([][]float32, len(resp.Data))
+		for i, data := range resp.Data {
+			result[i] = data.Embedding
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openai embedding failed: %w", err)
+	}
+
+	e.dimensions.observe(vectors)
+	return vectors, nil
+}
+
+// Dimensions 向量维度
+func (e *OpenAIEmbedder) Dimensions() int {
+	return e.dimensions.get()
+}
+
+// LocalEmbedder 本地模型向量化，兼容 Ollama 的 /api/embed 接口
+type LocalEmbedder struct {
+	client *http.Client
+	config *Config
+
+	// MaxBatchSize 单次请求最多的文本数，默认 64
+	MaxBatchSize int
+	// MaxBatchTokens 单次请求最多的 Token 数（按字符估算），默认 32768
+	MaxBatchTokens int
+
+	dimensions dimensionsOf
+}
+
+type localEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type localEmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// NewLocalEmbedder 创建本地模型向量化
+func NewLocalEmbedder(config *Config) *LocalEmbedder {
+	if config == nil {
+		config = &Config{
+			BaseURL:    localDefaultBaseURL,
+			Model:      "nomic-embed-text",
+			Timeout:    120 * time.Second,
+			MaxRetries: 3,
+		}
+	}
+
+	e := &LocalEmbedder{
+		client:         newHTTPClient(),
+		config:         config,
+		MaxBatchSize:   localEmbedMaxBatchSize,
+		MaxBatchTokens: localEmbedMaxBatchTokens,
+	}
+	e.dimensions.value = embeddingDimensions[longestPrefix(embeddingDimensions, config.Model)]
+	return e
+}
+
+// Embed 实现向量化接口
+func (e *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	vectors, err := embedInBatches(ctx, e.config.Model, texts, e.MaxBatchSize, e.MaxBatchTokens, func(ctx context.Context, batch []string) ([][]float32, error) {
+		var resp localEmbedResponse
+		_, err := withRetry(ctx, e.config, func(ctx context.Context) error {
+			return doJSON(ctx, e.client, http.MethodPost, e.url("/api/embed"), nil, localEmbedRequest{
+				Model: e.config.Model,
+				Input: batch,
+			}, &resp)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Embeddings, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local embedding failed: %w", err)
+	}
+
+	e.dimensions.observe(vectors)
+	return vectors, nil
+}
+
+// Dimensions 向量维度，未知模型在第一次调用后才能确定
+func (e *LocalEmbedder) Dimensions() int {
+	return e.dimensions.get()
+}
+
+// url 拼接接口地址
+func (e *LocalEmbedder) url(path string) string {
+	baseURL := e.config.BaseURL
+	if baseURL == "" {
+		baseURL = localDefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + path
+}
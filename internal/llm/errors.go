@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// 提供者错误分类，各提供者的错误都会归入其中之一，可用 errors.Is 判断
+var (
+	// ErrRateLimited 触发限流或配额不足
+	ErrRateLimited = errors.New("rate limited")
+	// ErrAuth 凭证无效或没有权限
+	ErrAuth = errors.New("authentication failed")
+	// ErrContextLength 输入超出模型的上下文长度
+	ErrContextLength = errors.New("context length exceeded")
+	// ErrContentFiltered 输入或输出被内容安全策略拦截
+	ErrContentFiltered = errors.New("content filtered")
+	// ErrTimeout 请求超时
+	ErrTimeout = errors.New("request timed out")
+	// ErrUnavailable 服务暂时不可用（5xx、过载、网络错误）
+	ErrUnavailable = errors.New("provider unavailable")
+)
+
+// ProviderError 归类后的提供者错误
+//
+// errors.Is(err, Kind) 为真，Err 为上游返回的原始错误。
+type ProviderError struct {
+	Kind       error
+	StatusCode int
+	Code       string
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error 实现 error 接口
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+// Is 使 errors.Is 可以匹配错误分类
+func (e *ProviderError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap 返回原始错误
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable 判断错误是否为可以重试的临时错误（限流、超时、服务不可用）
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnavailable)
+}
+
+// RetryAfter 返回上游要求的重试等待时间，没有时返回 0
+func RetryAfter(err error) time.Duration {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// classifyError 将各提供者的原始错误归类为 *ProviderError，无法归类时原样返回
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return err
+	}
+
+	// 调用方主动取消不属于提供者错误
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &ProviderError{Kind: ErrTimeout, Err: err}
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return newProviderError(errorKind(httpErr.StatusCode, httpErr.Code, httpErr.Message), httpErr.StatusCode, httpErr.Code, httpErr.RetryAfter, err)
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.Type
+		if apiErr.Code != nil {
+			code = fmt.Sprint(apiErr.Code)
+		}
+		return newProviderError(errorKind(apiErr.HTTPStatusCode, code, apiErr.Message), apiErr.HTTPStatusCode, code, 0, err)
+	}
+
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return newProviderError(errorKind(requestErr.HTTPStatusCode, "", ""), requestErr.HTTPStatusCode, "", 0, err)
+	}
+
+	var baiduErr *baiduError
+	if errors.As(err, &baiduErr) {
+		return newProviderError(baiduErr.kind(), 0, fmt.Sprint(baiduErr.Code), 0, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return &ProviderError{Kind: ErrTimeout, Err: err}
+		}
+		return &ProviderError{Kind: ErrUnavailable, Err: err}
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return &ProviderError{Kind: ErrUnavailable, Err: err}
+	}
+
+	return err
+}
+
+// streamError 将流中途返回的错误事件归类
+func streamError(code, message string) error {
+	err := errors.New(message)
+	if kind := errorKind(0, code, message); kind != nil {
+		return &ProviderError{Kind: kind, Code: code, Err: err}
+	}
+	return err
+}
+
+// newProviderError kind 为 nil 时原样返回 err
+func newProviderError(kind error, statusCode int, code string, retryAfter time.Duration, err error) error {
+	if kind == nil {
+		return err
+	}
+	return &ProviderError{Kind: kind, StatusCode: statusCode, Code: code, RetryAfter: retryAfter, Err: err}
+}
+
+// errorKind 根据状态码、错误码和错误信息判断错误分类
+//
+// 错误码覆盖 OpenAI / Azure（code）和 Claude（error.type），优先于状态码判断。
+func errorKind(statusCode int, code, message string) error {
+	switch code {
+	case "context_length_exceeded", "string_above_max_length":
+		return ErrContextLength
+	case "content_filter", "content_policy_violation", "ResponsibleAIPolicyViolation":
+		return ErrContentFiltered
+	case "rate_limit_exceeded", "rate_limit_error", "insufficient_quota":
+		return ErrRateLimited
+	case "invalid_api_key", "authentication_error", "permission_error":
+		return ErrAuth
+	case "overloaded_error", "api_error", "server_error":
+		return ErrUnavailable
+	}
+
+	lower := strings.ToLower(message)
+	if strings.Contains(lower, "context length") || strings.Contains(lower, "context window") ||
+		strings.Contains(lower, "maximum context") || strings.Contains(lower, "prompt is too long") {
+		return ErrContextLength
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrAuth
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrContextLength
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= 500:
+		return ErrUnavailable
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// FallbackEntry 降级链中的一个后端
+type FallbackEntry struct {
+	// Name 后端名称，记录在响应的 Provider 字段中，为空时使用 GetModelType()
+	Name     string
+	Provider Provider
+
+	// Model 发送给该后端的模型名，为空时沿用请求中的模型名
+	Model string
+
+	// Models 按请求中的模型名映射到该后端的模型名，优先于 Model
+	Models map[string]string
+}
+
+// model 返回发送给该后端的模型名
+func (e FallbackEntry) model(requested string) string {
+	if model, ok := e.Models[requested]; ok {
+		return model
+	}
+	if e.Model != "" {
+		return e.Model
+	}
+	return requested
+}
+
+// name 返回后端名称
+func (e FallbackEntry) name() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return string(e.Provider.GetModelType())
+}
+
+// FallbackProvider 按顺序尝试多个后端的提供者
+//
+// 当前后端返回可重试错误（限流、超时、服务不可用）时切换到下一个后端，其他错误直接返回。
+// 响应的 Provider 字段记录实际应答的后端。配置和模型类型取第一个后端的。
+type FallbackProvider struct {
+	entries []FallbackEntry
+}
+
+// NewFallbackProvider 创建降级提供者，entries 按优先级排列
+func NewFallbackProvider(entries ...FallbackEntry) *FallbackProvider {
+	return &FallbackProvider{entries: entries}
+}
+
+// Chat 实现聊天接口
+func (p *FallbackProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	var resp *ChatResponse
+	err := p.each(ctx, req.Model, func(ctx context.Context, entry FallbackEntry, model string) error {
+		entryReq := *req
+		entryReq.Model = model
+
+		var err error
+		resp, err = entry.Provider.Chat(ctx, &entryReq)
+		if err != nil {
+			return err
+		}
+		resp.Provider = entry.name()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ChatStream 实现流式聊天接口
+//
+// 在收到第一个数据块之前出错仍会切换后端；开始输出之后的错误直接通过数据块返回。
+func (p *FallbackProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	var (
+		stream <-chan ChatStreamChunk
+		first  ChatStreamChunk
+		ok     bool
+		name   string
+	)
+	err := p.each(ctx, req.Model, func(ctx context.Context, entry FallbackEntry, model string) error {
+		entryReq := *req
+		entryReq.Model = model
+
+		chunks, err := entry.Provider.ChatStream(ctx, &entryReq)
+		if err != nil {
+			return err
+		}
+
+		first, ok = <-chunks
+		if ok && first.Err != nil {
+			go func() {
+				for range chunks {
+				}
+			}()
+			return first.Err
+		}
+
+		stream, name = chunks, entry.name()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+		if !ok {
+			return
+		}
+
+		first.Provider = name
+		if !sendChunk(ctx, chunks, first) {
+			return
+		}
+		for chunk := range stream {
+			chunk.Provider = name
+			if !sendChunk(ctx, chunks, chunk) {
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口
+func (p *FallbackProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	var resp *CompletionResponse
+	err := p.each(ctx, req.Model, func(ctx context.Context, entry FallbackEntry, model string) error {
+		entryReq := *req
+		entryReq.Model = model
+
+		var err error
+		resp, err = entry.Provider.Complete(ctx, &entryReq)
+		if err != nil {
+			return err
+		}
+		resp.Provider = entry.name()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// each 按顺序对各后端执行 call，直到成功或遇到不可降级的错误
+//
+// 传给 call 的 ctx 清除了外层限流设置的重试函数，各后端的重试只经过它们自己的限流。
+func (p *FallbackProvider) each(ctx context.Context, requested string, call func(ctx context.Context, entry FallbackEntry, model string) error) error {
+	if len(p.entries) == 0 {
+		return fmt.Errorf("no providers configured for fallback")
+	}
+
+	callCtx := withoutRetryAttempt(ctx)
+
+	var lastErr error
+	for _, entry := range p.entries {
+		err := call(callCtx, entry, entry.model(requested))
+		if err == nil {
+			return nil
+		}
+
+		// 调用方取消或非临时错误（如鉴权失败、上下文超长）换后端也无济于事
+		if ctx.Err() != nil || !IsRetryable(err) {
+			return err
+		}
+		lastErr = fmt.Errorf("%s: %w", entry.name(), err)
+	}
+
+	return fmt.Errorf("all %d providers failed, last error: %w", len(p.entries), lastErr)
+}
+
+// GetConfig 获取第一个后端的配置
+func (p *FallbackProvider) GetConfig() *Config {
+	if len(p.entries) == 0 {
+		return nil
+	}
+	return p.entries[0].Provider.GetConfig()
+}
+
+// SetConfig 设置第一个后端的配置
+func (p *FallbackProvider) SetConfig(config *Config) {
+	if len(p.entries) > 0 {
+		p.entries[0].Provider.SetConfig(config)
+	}
+}
+
+// GetModelType 获取第一个后端的模型类型
+func (p *FallbackProvider) GetModelType() ModelType {
+	if len(p.entries) == 0 {
+		return ""
+	}
+	return p.entries[0].Provider.GetModelType()
+}
@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failingProvider 总是返回 err 并记录收到的模型名的测试提供者
+func failingProvider(err error, models *[]string) *chatFunc {
+	return &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+		*models = append(*models, req.Model)
+		if err != nil {
+			return nil, err
+		}
+		return &ChatResponse{Model: req.Model}, nil
+	}}
+}
+
+func TestFallbackSwitchesOnRetryableErrors(t *testing.T) {
+	for _, kind := range []error{ErrRateLimited, ErrTimeout, ErrUnavailable} {
+		var primary, backup []string
+		p := NewFallbackProvider(
+			FallbackEntry{Name: "primary", Provider: failingProvider(&ProviderError{Kind: kind, Err: errors.New("failed")}, &primary)},
+			FallbackEntry{Name: "backup", Provider: failingProvider(nil, &backup), Models: map[string]string{"gpt-4o": "claude-3-5-sonnet"}},
+		)
+
+		resp, err := p.Chat(context.Background(), &ChatRequest{Model: "gpt-4o"})
+		if err != nil {
+			t.Fatalf("%v: Chat error: %v", kind, err)
+		}
+		if resp.Provider != "backup" || resp.Model != "claude-3-5-sonnet" {
+			t.Errorf("%v: response from %s/%s, want backup/claude-3-5-sonnet", kind, resp.Provider, resp.Model)
+		}
+		if len(primary) != 1 || len(backup) != 1 {
+			t.Errorf("%v: calls = %d, %d, want 1, 1", kind, len(primary), len(backup))
+		}
+	}
+}
+
+func TestFallbackStopsOnNonRetryableErrors(t *testing.T) {
+	for _, kind := range []error{ErrAuth, ErrContextLength, ErrContentFiltered} {
+		var primary, backup []string
+		p := NewFallbackProvider(
+			FallbackEntry{Name: "primary", Provider: failingProvider(&ProviderError{Kind: kind, Err: errors.New("failed")}, &primary)},
+			FallbackEntry{Name: "backup", Provider: failingProvider(nil, &backup)},
+		)
+
+		_, err := p.Chat(context.Background(), &ChatRequest{})
+		if !errors.Is(err, kind) {
+			t.Errorf("%v: Chat error = %v, want the primary's error", kind, err)
+		}
+		if len(backup) != 0 {
+			t.Errorf("%v: backup called %d times, want 0", kind, len(backup))
+		}
+	}
+}
+
+func TestFallbackAllFailed(t *testing.T) {
+	var primary, backup []string
+	p := NewFallbackProvider(
+		FallbackEntry{Name: "primary", Provider: failingProvider(&ProviderError{Kind: ErrUnavailable, Err: errors.New("down")}, &primary)},
+		FallbackEntry{Name: "backup", Provider: failingProvider(&ProviderError{Kind: ErrRateLimited, Err: errors.New("busy")}, &backup)},
+	)
+
+	_, err := p.Chat(context.Background(), &ChatRequest{})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Chat error = %v, want the last backend's error", err)
+	}
+	if len(primary) != 1 || len(backup) != 1 {
+		t.Errorf("calls = %d, %d, want 1, 1", len(primary), len(backup))
+	}
+}
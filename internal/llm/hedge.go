@@ -0,0 +1,407 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	hedgeDefaultQuantile   = 0.95
+	hedgeDefaultMinSamples = 20
+	hedgeDefaultDelay      = 2 * time.Second
+	hedgeDefaultBudget     = 0.1
+
+	// hedgeBudgetBurst 预算最多累积的对冲次数，避免长时间空闲后集中对冲
+	hedgeBudgetBurst = 10
+)
+
+// HedgeConfig 对冲请求配置
+type HedgeConfig struct {
+	// Metrics 延迟统计，对冲延迟取第一个后端延迟的 Quantile 分位数；为空或样本不足 MinSamples 时使用 Delay
+	//
+	// 应是包装该提供者的 LatencyMetrics.Middleware 所记录的统计，两者按相同的后端和模型取键。
+	Metrics *LatencyMetrics
+
+	// Quantile 对冲延迟使用的分位数，默认 0.95
+	Quantile float64
+
+	// MinSamples 使用延迟统计所需的最少样本数，默认 20
+	MinSamples int
+
+	// Delay 没有延迟统计时的对冲延迟，默认 2 秒
+	Delay time.Duration
+
+	// Budget 对冲预算，为空时创建按 10% 比例限制的预算；注册表中同一后端的所有请求应共用一个预算
+	Budget *HedgeBudget
+}
+
+// HedgeStats 对冲请求的统计
+type HedgeStats struct {
+	Requests  uint64 `json:"requests"`
+	Hedges    uint64 `json:"hedges"`
+	HedgeWins uint64 `json:"hedge_wins"`
+
+	// Throttled 到达对冲延迟但预算不足、未发出对冲请求的次数
+	Throttled uint64 `json:"throttled"`
+}
+
+// HedgeBudget 限制对冲请求占总请求比例的预算，可并发使用
+//
+// 每个请求按比例存入额度，每次对冲消耗 1，长期来看对冲请求数不超过总请求数乘以比例。
+type HedgeBudget struct {
+	ratio float64
+
+	mu     sync.Mutex
+	tokens float64
+	stats  HedgeStats
+}
+
+// NewHedgeBudget 创建对冲预算，ratio 为对冲请求占总请求的比例上限，如 0.1
+func NewHedgeBudget(ratio float64) *HedgeBudget {
+	return &HedgeBudget{ratio: ratio}
+}
+
+// Stats 返回对冲请求的统计
+func (b *HedgeBudget) Stats() HedgeStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// deposit 记录一个请求并存入额度
+func (b *HedgeBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stats.Requests++
+	b.tokens += b.ratio
+	if b.tokens > hedgeBudgetBurst {
+		b.tokens = hedgeBudgetBurst
+	}
+}
+
+// withdraw 额度足够时消耗一次对冲
+func (b *HedgeBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		b.stats.Throttled++
+		return false
+	}
+	b.tokens--
+	b.stats.Hedges++
+	return true
+}
+
+// won 记录一次对冲请求先于原请求成功
+func (b *HedgeBudget) won() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stats.HedgeWins++
+}
+
+// HedgedProvider 对冲请求的提供者
+//
+// 先调用第一个后端，超过对冲延迟（默认取该后端延迟的 p95）仍未返回时，在预算允许的情况下向下一个后端
+// 再发一次请求；只有一个后端时对冲请求发往同一后端（使用号池时会选中另一个 Key）。先成功的响应胜出，
+// 其余请求通过 ctx 取消。后端返回可重试错误时与 FallbackProvider 一样立即切换到下一个后端，不消耗预算。
+// 流式调用以收到第一个数据块为准。
+//
+// 延迟统计记录的是胜出请求的耗时，对冲会使分布偏低，预算保证对冲比例不会因此失控。
+type HedgedProvider struct {
+	config  *HedgeConfig
+	entries []FallbackEntry
+}
+
+// NewHedgedProvider 创建对冲提供者，entries 按优先级排列
+//
+// 默认值写入 config 的副本，注册表每次请求都会用同一个 config 创建对冲提供者，不能修改它。
+func NewHedgedProvider(config *HedgeConfig, entries ...FallbackEntry) *HedgedProvider {
+	var c HedgeConfig
+	if config != nil {
+		c = *config
+	}
+	if c.Quantile <= 0 || c.Quantile >= 1 {
+		c.Quantile = hedgeDefaultQuantile
+	}
+	if c.MinSamples <= 0 {
+		c.MinSamples = hedgeDefaultMinSamples
+	}
+	if c.Delay <= 0 {
+		c.Delay = hedgeDefaultDelay
+	}
+	if c.Budget == nil {
+		c.Budget = NewHedgeBudget(hedgeDefaultBudget)
+	}
+
+	return &HedgedProvider{config: &c, entries: entries}
+}
+
+// Chat 实现聊天接口
+func (p *HedgedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	resp, _, done, err := hedge(ctx, p, MethodChat, req.Model, func(ctx context.Context, entry FallbackEntry, model string) (*ChatResponse, error) {
+		entryReq := *req
+		entryReq.Model = model
+
+		resp, err := entry.Provider.Chat(ctx, &entryReq)
+		if err != nil {
+			return nil, err
+		}
+		resp.Provider = entry.name()
+		return resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	done()
+	return resp, nil
+}
+
+// hedgedStream 已收到第一个数据块的流
+type hedgedStream struct {
+	stream <-chan ChatStreamChunk
+	first  ChatStreamChunk
+	ok     bool
+}
+
+// ChatStream 实现流式聊天接口
+//
+// 在收到第一个数据块之前出错或超过对冲延迟时发出新的请求；开始输出之后的错误直接通过数据块返回。
+func (p *HedgedProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	winner, name, done, err := hedge(ctx, p, MethodChatStream, req.Model, func(ctx context.Context, entry FallbackEntry, model string) (*hedgedStream, error) {
+		entryReq := *req
+		entryReq.Model = model
+
+		stream, err := entry.Provider.ChatStream(ctx, &entryReq)
+		if err != nil {
+			return nil, err
+		}
+
+		first, ok := <-stream
+		if ok && first.Err != nil {
+			go func() {
+				for range stream {
+				}
+			}()
+			return nil, first.Err
+		}
+		return &hedgedStream{stream: stream, first: first, ok: ok}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+		defer done()
+		if !winner.ok {
+			return
+		}
+
+		winner.first.Provider = name
+		if !sendChunk(ctx, chunks, winner.first) {
+			return
+		}
+		for chunk := range winner.stream {
+			chunk.Provider = name
+			if !sendChunk(ctx, chunks, chunk) {
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口
+func (p *HedgedProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	resp, _, done, err := hedge(ctx, p, MethodComplete, req.Model, func(ctx context.Context, entry FallbackEntry, model string) (*CompletionResponse, error) {
+		entryReq := *req
+		entryReq.Model = model
+
+		resp, err := entry.Provider.Complete(ctx, &entryReq)
+		if err != nil {
+			return nil, err
+		}
+		resp.Provider = entry.name()
+		return resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	done()
+	return resp, nil
+}
+
+// delay 返回第一个后端的对冲延迟
+//
+// 与延迟中间件取相同的键：后端为第一个后端（即其应答时响应中的 Provider），模型为请求的模型，未指定时为配置中的模型。
+func (p *HedgedProvider) delay(method, model string) time.Duration {
+	if p.config.Metrics == nil {
+		return p.config.Delay
+	}
+
+	key := LatencyKey(method, p.entries[0].name(), latencyModel(model, p))
+	histogram := p.config.Metrics.Histogram(key)
+	if histogram == nil || histogram.Count() < uint64(p.config.MinSamples) {
+		return p.config.Delay
+	}
+	if delay, ok := histogram.Quantile(p.config.Quantile); ok && delay > 0 {
+		return delay
+	}
+	return p.config.Delay
+}
+
+// hedgeResult 一次请求的结果
+type hedgeResult[T interface{}] struct {
+	value T
+	err   error
+	index int
+}
+
+// hedge 按对冲策略调用各后端，返回胜出的结果、后端名称和释放胜出请求 ctx 的 done
+//
+// 胜出之外的请求在返回前取消；done 应在不再使用胜出结果（如流读取完毕）后执行。
+func hedge[T interface{}](ctx context.Context, p *HedgedProvider, method, requested string, call func(ctx context.Context, entry FallbackEntry, model string) (T, error)) (T, string, func(), error) {
+	var zero T
+	if len(p.entries) == 0 {
+		return zero, "", nil, fmt.Errorf("no providers configured for hedging")
+	}
+
+	budget := p.config.Budget
+	budget.deposit()
+
+	var (
+		results  = make(chan hedgeResult[T], len(p.entries)+1)
+		cancels  []context.CancelFunc
+		names    []string
+		hedged   = -1
+		next     = 0
+		inFlight = 0
+	)
+	cancelAll := func(except int) {
+		for i, cancel := range cancels {
+			if i != except {
+				cancel()
+			}
+		}
+	}
+	launch := func(i int) {
+		entry := p.entries[i]
+		if i == next {
+			next++
+		}
+
+		attemptCtx, cancel := context.WithCancel(withoutRetryAttempt(ctx))
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		names = append(names, entry.name())
+		inFlight++
+
+		go func() {
+			value, err := call(attemptCtx, entry, entry.model(requested))
+			results <- hedgeResult[T]{value: value, err: err, index: index}
+		}()
+	}
+	// target 返回下一个尚未调用的后端，只有一个后端时对冲请求可以再次发往该后端
+	target := func(hedging bool) (int, bool) {
+		if next < len(p.entries) {
+			return next, true
+		}
+		if hedging && len(p.entries) == 1 && len(cancels) == 1 {
+			return 0, true
+		}
+		return 0, false
+	}
+
+	launch(0)
+
+	timer := time.NewTimer(p.delay(method, requested))
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case result := <-results:
+			inFlight--
+			if result.err == nil {
+				cancelAll(result.index)
+				if result.index == hedged {
+					budget.won()
+				}
+				return result.value, names[result.index], cancels[result.index], nil
+			}
+
+			cancels[result.index]()
+			lastErr = fmt.Errorf("%s: %w", names[result.index], result.err)
+
+			// 可重试错误立即切换到下一个后端，其他错误在没有进行中的请求时返回
+			if ctx.Err() == nil && IsRetryable(result.err) {
+				if i, ok := target(false); ok {
+					launch(i)
+					continue
+				}
+			}
+			if inFlight == 0 {
+				if len(cancels) == 1 {
+					return zero, "", nil, result.err
+				}
+				return zero, "", nil, fmt.Errorf("all %d hedged requests failed, last error: %w", len(cancels), lastErr)
+			}
+
+		case <-timer.C:
+			if hedged >= 0 {
+				continue
+			}
+			i, ok := target(true)
+			if !ok || !budget.withdraw() {
+				continue
+			}
+			hedged = len(cancels)
+			launch(i)
+
+		case <-ctx.Done():
+			cancelAll(-1)
+			return zero, "", nil, ctx.Err()
+		}
+	}
+}
+
+// GetConfig 获取第一个后端的配置
+func (p *HedgedProvider) GetConfig() *Config {
+	if len(p.entries) == 0 {
+		return nil
+	}
+	return p.entries[0].Provider.GetConfig()
+}
+
+// SetConfig 设置第一个后端的配置
+func (p *HedgedProvider) SetConfig(config *Config) {
+	if len(p.entries) > 0 {
+		p.entries[0].Provider.SetConfig(config)
+	}
+}
+
+// GetModelType 获取第一个后端的模型类型
+func (p *HedgedProvider) GetModelType() ModelType {
+	if len(p.entries) == 0 {
+		return ""
+	}
+	return p.entries[0].Provider.GetModelType()
+}
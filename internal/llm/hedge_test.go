@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHedgeSendsBackupAfterDelay(t *testing.T) {
+	budget := NewHedgeBudget(1)
+	hedged := NewHedgedProvider(&HedgeConfig{Delay: 10 * time.Millisecond, Budget: budget},
+		FallbackEntry{Name: "primary", Provider: &stubProvider{config: &Config{}, latency: 500 * time.Millisecond}},
+		FallbackEntry{Name: "backup", Provider: &stubProvider{config: &Config{}}},
+	)
+
+	start := time.Now()
+	resp, err := hedged.Chat(context.Background(), &ChatRequest{})
+	if err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if resp.Provider != "backup" {
+		t.Errorf("Provider = %q, want backup", resp.Provider)
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Errorf("Chat took %v, want hedged response before primary finishes", elapsed)
+	}
+
+	stats := budget.Stats()
+	if stats.Requests != 1 || stats.Hedges != 1 || stats.HedgeWins != 1 || stats.Throttled != 0 {
+		t.Errorf("stats = %+v, want 1 request, 1 hedge, 1 win", stats)
+	}
+}
+
+func TestHedgeWaitsForDelay(t *testing.T) {
+	budget := NewHedgeBudget(1)
+	hedged := NewHedgedProvider(&HedgeConfig{Delay: time.Second, Budget: budget},
+		FallbackEntry{Name: "primary", Provider: &stubProvider{config: &Config{}, latency: 20 * time.Millisecond}},
+		FallbackEntry{Name: "backup", Provider: &stubProvider{config: &Config{}}},
+	)
+
+	resp, err := hedged.Chat(context.Background(), &ChatRequest{})
+	if err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if resp.Provider != "primary" {
+		t.Errorf("Provider = %q, want primary", resp.Provider)
+	}
+	if stats := budget.Stats(); stats.Hedges != 0 {
+		t.Errorf("Hedges = %d, want 0 before the delay elapses", stats.Hedges)
+	}
+}
+
+func TestHedgeBudgetThrottles(t *testing.T) {
+	budget := NewHedgeBudget(0.5)
+	hedged := NewHedgedProvider(&HedgeConfig{Delay: time.Millisecond, Budget: budget},
+		FallbackEntry{Name: "primary", Provider: &stubProvider{config: &Config{}, latency: 30 * time.Millisecond}},
+		FallbackEntry{Name: "backup", Provider: &stubProvider{config: &Config{}}},
+	)
+
+	// 第一个请求只存入 0.5 的额度，不足以对冲；第二个请求累积到 1，可以对冲一次
+	want := []string{"primary", "backup"}
+	for i, provider := range want {
+		resp, err := hedged.Chat(context.Background(), &ChatRequest{})
+		if err != nil {
+			t.Fatalf("Chat #%d error: %v", i, err)
+		}
+		if resp.Provider != provider {
+			t.Errorf("Chat #%d Provider = %q, want %q", i, resp.Provider, provider)
+		}
+	}
+
+	stats := budget.Stats()
+	if stats.Requests != 2 || stats.Hedges != 1 || stats.Throttled != 1 {
+		t.Errorf("stats = %+v, want 2 requests, 1 hedge, 1 throttled", stats)
+	}
+}
+
+func TestHedgeDelayFromMetrics(t *testing.T) {
+	metrics := NewLatencyMetrics()
+	hedged := NewHedgedProvider(&HedgeConfig{Metrics: metrics, MinSamples: 3, Delay: time.Hour},
+		FallbackEntry{Name: "primary", Provider: &stubProvider{config: &Config{Model: "m"}}},
+	)
+
+	key := LatencyKey(MethodChat, "primary", "m")
+	for i := 0; i < 2; i++ {
+		metrics.histogram(key).Observe(50 * time.Millisecond)
+	}
+	if delay := hedged.delay(MethodChat, ""); delay != time.Hour {
+		t.Errorf("delay with too few samples = %v, want fallback delay", delay)
+	}
+
+	metrics.histogram(key).Observe(50 * time.Millisecond)
+	if delay := hedged.delay(MethodChat, ""); delay == time.Hour || delay > time.Second {
+		t.Errorf("delay = %v, want recorded latency quantile", delay)
+	}
+}
+
+func TestRegistryHedgingConcurrent(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("stub", func(config *Config) (Provider, error) {
+		return &stubProvider{config: config, modelType: "stub"}, nil
+	})
+	registry.Configure("stub", &Config{Model: "m"})
+
+	config := &HedgeConfig{}
+	registry.SetHedging("stub", config)
+	if config.Budget != nil {
+		t.Errorf("SetHedging modified the caller's config")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			provider, err := registry.Provider("stub")
+			if err != nil {
+				t.Errorf("Provider error: %v", err)
+				return
+			}
+			if _, err := provider.Chat(context.Background(), &ChatRequest{}); err != nil {
+				t.Errorf("Chat error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
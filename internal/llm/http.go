@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPError 上游 HTTP 接口返回的错误
+type HTTPError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+// Error 实现 error 接口
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("status code: %d, message: %s", e.StatusCode, e.Message)
+}
+
+// doJSON 发送 JSON 请求并将响应解析到 out
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, body, out interface{}) error {
+	resp, err := sendRequest(ctx, client, method, url, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// sendRequest 发送 JSON 请求，非 2xx 状态码会转换为 *HTTPError
+func sendRequest(ctx context.Context, client *http.Client, method, url string, header http.Header, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		for _, value := range values {
+			httpReq.Header.Add(key, value)
+		}
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		message, code := errorDetails(data)
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Code:       code,
+			Message:    message,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return resp, nil
+}
+
+// errorDetails 从常见的错误响应体中提取错误信息和错误码
+//
+// 支持 {"error": {"message", "code" / "type"}}、{"error": "..."} 和 {"message": "..."} 三种格式。
+func errorDetails(data []byte) (message, code string) {
+	var body struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil {
+		var nested struct {
+			Message string      `json:"message"`
+			Code    interface{} `json:"code"`
+			Type    string      `json:"type"`
+		}
+		if json.Unmarshal(body.Error, &nested) == nil && nested.Message != "" {
+			code = nested.Type
+			if nested.Code != nil {
+				code = fmt.Sprint(nested.Code)
+			}
+			return nested.Message, code
+		}
+
+		var plain string
+		if json.Unmarshal(body.Error, &plain) == nil && plain != "" {
+			return plain, ""
+		}
+
+		if body.Message != "" {
+			return body.Message, ""
+		}
+	}
+
+	return strings.TrimSpace(string(data)), ""
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// readSSE 逐个读取 Server-Sent Events 事件，fn 返回 false 时停止读取
+func readSSE(r io.Reader, fn func(event, data string) bool) error {
+	reader := bufio.NewReader(r)
+
+	var (
+		event string
+		data  strings.Builder
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			// 空行表示一个事件结束
+			if data.Len() > 0 {
+				if !fn(event, data.String()) {
+					return nil
+				}
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// 注释行，忽略
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteString("\n")
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+
+		if err == io.EOF {
+			if data.Len() > 0 {
+				fn(event, data.String())
+			}
+			return nil
+		}
+	}
+}
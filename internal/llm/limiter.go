@@ -0,0 +1,314 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LimiterConfig 客户端限流配置，为 0 的项不限制
+type LimiterConfig struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+	MaxInFlight       int
+
+	// EstimateTokens 调用前估算请求消耗的 Token 数（提示词加最大输出），为空时按模型的分词器计算
+	EstimateTokens func(req *ChatRequest) int
+}
+
+// LimitedProvider 在调用前按 RPM、TPM 和并发数排队等待的提供者
+//
+// Token 消耗在调用前按估算值预扣，调用结束后按 Usage 多退少补；超出限额的调用会排队等待而不是失败，
+// 等待受 ctx 控制。提供者内部的每次重试同样按 RPM 排队并计入请求数；Token 只在整个调用结束后按实际用量修正，
+// 失败的尝试不计 Token。
+type LimitedProvider struct {
+	Provider
+
+	config   *LimiterConfig
+	requests *tokenBucket
+	tokens   *tokenBucket
+	slots    chan struct{}
+}
+
+// NewLimitedProvider 创建限流提供者
+func NewLimitedProvider(provider Provider, config *LimiterConfig) *LimitedProvider {
+	if config == nil {
+		config = &LimiterConfig{}
+	}
+
+	p := &LimitedProvider{Provider: provider, config: config}
+	if config.RequestsPerMinute > 0 {
+		p.requests = newTokenBucket(config.RequestsPerMinute)
+	}
+	if config.TokensPerMinute > 0 {
+		p.tokens = newTokenBucket(config.TokensPerMinute)
+	}
+	if config.MaxInFlight > 0 {
+		p.slots = make(chan struct{}, config.MaxInFlight)
+	}
+
+	return p
+}
+
+// withLimits 配置了限流项时用 LimitedProvider 包装提供者
+func withLimits(provider Provider, config *Config) Provider {
+	if config == nil || (config.RequestsPerMinute <= 0 && config.TokensPerMinute <= 0 && config.MaxInFlight <= 0) {
+		return provider
+	}
+
+	return NewLimitedProvider(provider, &LimiterConfig{
+		RequestsPerMinute: config.RequestsPerMinute,
+		TokensPerMinute:   config.TokensPerMinute,
+		MaxInFlight:       config.MaxInFlight,
+	})
+}
+
+// Chat 实现聊天接口
+func (p *LimitedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	charged, release, err := p.acquire(ctx, p.estimate(req))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := p.Provider.Chat(p.limitRetries(ctx), req)
+	if err != nil {
+		p.correct(charged, 0)
+		return nil, err
+	}
+
+	p.correct(charged, usedTokens(resp.Usage, charged))
+	return resp, nil
+}
+
+// ChatStream 实现流式聊天接口，并发名额在流结束时才释放
+func (p *LimitedProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	charged, release, err := p.acquire(ctx, p.estimate(req))
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := p.Provider.ChatStream(p.limitRetries(ctx), req)
+	if err != nil {
+		release()
+		p.correct(charged, 0)
+		return nil, err
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+		defer release()
+
+		// 没有收到 Usage 时按预扣值计
+		used := charged
+		defer func() { p.correct(charged, used) }()
+
+		for chunk := range stream {
+			if chunk.Usage != nil {
+				used = chunk.Usage.TotalTokens
+			}
+			if !sendChunk(ctx, chunks, chunk) {
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口
+func (p *LimitedProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	estimate := p.estimate(&ChatRequest{
+		Model:     req.Model,
+		Messages:  []Message{{Role: RoleUser, Content: req.Prompt}},
+		MaxTokens: req.MaxTokens,
+	})
+	charged, release, err := p.acquire(ctx, estimate)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := p.Provider.Complete(p.limitRetries(ctx), req)
+	if err != nil {
+		p.correct(charged, 0)
+		return nil, err
+	}
+
+	p.correct(charged, usedTokens(resp.Usage, charged))
+	return resp, nil
+}
+
+// acquire 依次等待 RPM、TPM 和并发名额，返回实际预扣的 Token 数和释放并发名额的函数
+//
+// 后面的等待失败时退回前面已扣除的请求数和 Token。
+func (p *LimitedProvider) acquire(ctx context.Context, estimate int) (int, func(), error) {
+	if p.requests != nil {
+		if _, err := p.requests.wait(ctx, 1); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var charged int
+	if p.tokens != nil {
+		var err error
+		if charged, err = p.tokens.wait(ctx, estimate); err != nil {
+			p.refundRequest()
+			return 0, nil, err
+		}
+	}
+
+	if p.slots == nil {
+		return charged, func() {}, nil
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		p.refundRequest()
+		p.correct(charged, 0)
+		return 0, nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return charged, func() { once.Do(func() { <-p.slots }) }, nil
+}
+
+// limitRetries 返回每次重试前按 RPM 排队的 context，重试同样计入请求数
+func (p *LimitedProvider) limitRetries(ctx context.Context) context.Context {
+	if p.requests == nil {
+		return ctx
+	}
+	return withRetryAttempt(ctx, func(ctx context.Context) error {
+		_, err := p.requests.wait(ctx, 1)
+		return err
+	})
+}
+
+// refundRequest 退回 acquire 中已扣除的请求数
+func (p *LimitedProvider) refundRequest() {
+	if p.requests != nil {
+		p.requests.adjust(-1)
+	}
+}
+
+// correct 按实际用量修正预扣的 Token，charged 为 acquire 实际预扣的数量，调用失败时 used 为 0 即全部退回
+func (p *LimitedProvider) correct(charged, used int) {
+	if p.tokens != nil {
+		p.tokens.adjust(used - charged)
+	}
+}
+
+// usedTokens 返回实际用量，上游没有返回 Usage 时使用预扣值
+func usedTokens(usage Usage, charged int) int {
+	if usage.TotalTokens > 0 {
+		return usage.TotalTokens
+	}
+	return charged
+}
+
+// estimate 估算请求消耗的 Token 数
+func (p *LimitedProvider) estimate(req *ChatRequest) int {
+	if p.config.EstimateTokens != nil {
+		return p.config.EstimateTokens(req)
+	}
+
+	tokens := CountMessageTokens(TokenizerForModel(req.Model), req.Messages)
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		if config := p.GetConfig(); config != nil {
+			maxTokens = config.MaxTokens
+		}
+	}
+
+	return tokens + maxTokens
+}
+
+// tokenBucket 令牌桶，每分钟补充 capacity 个令牌
+//
+// 令牌可以预扣为负数，之后的调用按欠额排队等待，从而保证先到先得。
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / time.Minute.Seconds(),
+		tokens:   float64(perMinute),
+		last:     time.Now(),
+	}
+}
+
+// wait 预扣 n 个令牌，不足时等待补充，返回实际预扣的令牌数；ctx 结束时退回预扣的令牌
+func (b *tokenBucket) wait(ctx context.Context, n int) (int, error) {
+	if float64(n) > b.capacity {
+		// 单次超过桶容量时按容量计算，避免永远等不到
+		n = int(b.capacity)
+	}
+
+	b.mu.Lock()
+	b.refill()
+	b.tokens -= float64(n)
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return n, nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return n, nil
+	case <-ctx.Done():
+		b.adjust(-n)
+		return 0, ctx.Err()
+	}
+}
+
+// adjust 额外扣除 delta 个令牌，delta 为负数时退回
+func (b *tokenBucket) adjust(delta int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens -= float64(delta)
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// refill 按经过的时间补充令牌，调用方需持有锁
+func (b *tokenBucket) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
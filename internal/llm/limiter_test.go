@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// chatFunc 只实现 Chat 的测试提供者
+type chatFunc struct {
+	Provider
+
+	chat func(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+}
+
+func (p *chatFunc) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	return p.chat(ctx, req)
+}
+
+// bucketTokens 返回令牌桶当前的令牌数，忽略调用期间补充的零头
+func bucketTokens(b *tokenBucket) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	return math.Floor(b.tokens)
+}
+
+func fixedEstimate(n int) func(req *ChatRequest) int {
+	return func(req *ChatRequest) int { return n }
+}
+
+func TestLimitedProviderCorrectsClampedEstimate(t *testing.T) {
+	upstream := &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+		return &ChatResponse{Usage: Usage{TotalTokens: 60}}, nil
+	}}
+	// 估算值超过桶容量，只预扣 100 个
+	p := NewLimitedProvider(upstream, &LimiterConfig{TokensPerMinute: 100, EstimateTokens: fixedEstimate(150)})
+
+	if _, err := p.Chat(context.Background(), &ChatRequest{}); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if got := bucketTokens(p.tokens); got != 40 {
+		t.Errorf("tokens = %v, want 40 left after using 60", got)
+	}
+}
+
+func TestLimitedProviderRefundsRequestOnFailedWait(t *testing.T) {
+	upstream := &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+		return &ChatResponse{Usage: Usage{TotalTokens: 100}}, nil
+	}}
+	p := NewLimitedProvider(upstream, &LimiterConfig{RequestsPerMinute: 10, TokensPerMinute: 100, EstimateTokens: fixedEstimate(100)})
+
+	if _, err := p.Chat(context.Background(), &ChatRequest{}); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+
+	// Token 已用完，第二次调用在等待 TPM 时超时
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Chat(ctx, &ChatRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Chat error = %v, want context.DeadlineExceeded", err)
+	}
+
+	if got := bucketTokens(p.requests); got != 9 {
+		t.Errorf("requests = %v, want 9 after one completed call", got)
+	}
+}
+
+func TestLimitedProviderRefundsOnFailedSlot(t *testing.T) {
+	block := make(chan struct{})
+	upstream := &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+		<-block
+		return &ChatResponse{Usage: Usage{TotalTokens: 10}}, nil
+	}}
+	p := NewLimitedProvider(upstream, &LimiterConfig{RequestsPerMinute: 10, TokensPerMinute: 100, MaxInFlight: 1, EstimateTokens: fixedEstimate(10)})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Chat(context.Background(), &ChatRequest{})
+	}()
+	for len(p.slots) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Chat(ctx, &ChatRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Chat error = %v, want context.DeadlineExceeded", err)
+	}
+	close(block)
+	<-done
+
+	if got := bucketTokens(p.requests); got != 9 {
+		t.Errorf("requests = %v, want 9", got)
+	}
+	if got := bucketTokens(p.tokens); got != 90 {
+		t.Errorf("tokens = %v, want 90", got)
+	}
+}
+
+func TestLimitedProviderChargesRetries(t *testing.T) {
+	config := &Config{MaxRetries: 1}
+	attempts := 0
+	upstream := &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+		_, err := withRetry(ctx, config, func(ctx context.Context) error {
+			attempts++
+			if attempts == 1 {
+				return &ProviderError{Kind: ErrUnavailable, Err: errors.New("overloaded")}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &ChatResponse{}, nil
+	}}
+	p := NewLimitedProvider(upstream, &LimiterConfig{RequestsPerMinute: 10, EstimateTokens: fixedEstimate(1)})
+
+	if _, err := p.Chat(context.Background(), &ChatRequest{}); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+	if got := bucketTokens(p.requests); got != 8 {
+		t.Errorf("requests = %v, want 8 after a call with one retry", got)
+	}
+}
+
+func TestLimitedProviderDoesNotChargeNestedRetries(t *testing.T) {
+	config := &Config{MaxRetries: 1}
+	attempts := 0
+	backend := &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+		_, err := withRetry(ctx, config, func(ctx context.Context) error {
+			attempts++
+			if ctx.Value(retryAttemptKey{}) != nil {
+				t.Errorf("attempt %d sees the limiter's retry hook", attempts)
+			}
+			if attempts == 1 {
+				return &ProviderError{Kind: ErrUnavailable, Err: errors.New("overloaded")}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &ChatResponse{}, nil
+	}}
+	fallback := NewFallbackProvider(FallbackEntry{Name: "backend", Provider: backend})
+	p := NewLimitedProvider(fallback, &LimiterConfig{RequestsPerMinute: 10, EstimateTokens: fixedEstimate(1)})
+
+	if _, err := p.Chat(context.Background(), &ChatRequest{}); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+	if got := bucketTokens(p.requests); got != 9 {
+		t.Errorf("requests = %v, want 9: retries inside the fallback chain are not the limiter's", got)
+	}
+}
@@ -0,0 +1,384 @@
+// Package llmtest 提供不访问网络的 llm.Provider 实现，用于单元测试
+//
+// MockProvider 按脚本匹配请求并返回预设的响应、错误和延迟；Recorder 将真实调用录制为 golden 文件，
+// 之后离线回放。
+package llmtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-llm-tools/internal/llm"
+)
+
+// ModelTypeMock 测试提供者的模型类型
+const ModelTypeMock llm.ModelType = "mock"
+
+// Matcher 判断请求是否匹配
+type Matcher func(req *llm.ChatRequest) bool
+
+// Any 匹配所有请求
+func Any() Matcher {
+	return func(req *llm.ChatRequest) bool { return true }
+}
+
+// ModelIs 匹配指定模型的请求
+func ModelIs(model string) Matcher {
+	return func(req *llm.ChatRequest) bool { return req.Model == model }
+}
+
+// Contains 匹配最后一条消息包含 substr 的请求
+func Contains(substr string) Matcher {
+	return func(req *llm.ChatRequest) bool {
+		return len(req.Messages) > 0 && strings.Contains(req.Messages[len(req.Messages)-1].Content, substr)
+	}
+}
+
+// Expectation 一条脚本：匹配的请求返回的响应、错误和延迟
+type Expectation struct {
+	match    Matcher
+	response *llm.ChatResponse
+	chunks   []llm.ChatStreamChunk
+	err      error
+	delay    time.Duration
+
+	// times 剩余可匹配次数，小于 0 表示不限
+	times int
+	calls int
+
+	owner *MockProvider
+}
+
+// Reply 返回内容为 content 的助手消息
+func (e *Expectation) Reply(content string) *Expectation {
+	e.response = &llm.ChatResponse{
+		Choices: []llm.ChatChoice{{
+			Message:      llm.Message{Role: llm.RoleAssistant, Content: content},
+			FinishReason: "stop",
+		}},
+	}
+	return e
+}
+
+// ReplyWith 返回完整的响应，Model 和 Usage 为空时自动填写
+func (e *Expectation) ReplyWith(resp *llm.ChatResponse) *Expectation {
+	e.response = resp
+	return e
+}
+
+// StreamChunks 流式调用时按顺序返回 chunks，不设置时由响应内容按词切分生成
+func (e *Expectation) StreamChunks(chunks ...llm.ChatStreamChunk) *Expectation {
+	e.chunks = chunks
+	return e
+}
+
+// Fail 返回错误，可以是 llm.ErrRateLimited 等分类错误或 *llm.ProviderError
+func (e *Expectation) Fail(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Delay 返回前等待 d，等待期间 ctx 结束时返回 ctx.Err()
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// Times 最多匹配 n 次，之后交给后面的脚本
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// Once 只匹配一次
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// MockProvider 按脚本返回结果的提供者
+//
+// 脚本按添加顺序匹配，使用第一条匹配且未用完次数的脚本；没有匹配的脚本时返回错误。
+// 所有收到的请求都会被记录，可用 Requests 检查。
+type MockProvider struct {
+	config *llm.Config
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	requests     []*llm.ChatRequest
+}
+
+// NewMockProvider 创建测试提供者
+func NewMockProvider(config *llm.Config) *MockProvider {
+	if config == nil {
+		config = &llm.Config{
+			Model:       "mock-model",
+			Temperature: 0.7,
+			MaxTokens:   1000,
+		}
+	}
+
+	return &MockProvider{config: config}
+}
+
+// Factory 返回始终创建该实例的构造函数，用于注册到 llm.Registry
+func (p *MockProvider) Factory() llm.Factory {
+	return func(config *llm.Config) (llm.Provider, error) {
+		if config != nil {
+			p.SetConfig(config)
+		}
+		return p, nil
+	}
+}
+
+// On 添加一条脚本
+func (p *MockProvider) On(match Matcher) *Expectation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e := &Expectation{match: match, times: -1, owner: p}
+	p.expectations = append(p.expectations, e)
+	return e
+}
+
+// Requests 返回收到的所有请求（补全请求转换为单条用户消息的聊天请求）
+func (p *MockProvider) Requests() []*llm.ChatRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*llm.ChatRequest(nil), p.requests...)
+}
+
+// Calls 返回脚本被匹配的次数
+func (e *Expectation) Calls() int {
+	e.owner.mu.Lock()
+	defer e.owner.mu.Unlock()
+	return e.calls
+}
+
+// Chat 实现聊天接口
+func (p *MockProvider) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	e, seq, err := p.match(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.response(e, req, seq), nil
+}
+
+// ChatStream 实现流式聊天接口，错误在建立流时返回
+func (p *MockProvider) ChatStream(ctx context.Context, req *llm.ChatRequest) (<-chan llm.ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	e, seq, err := p.match(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := e.chunks
+	if chunks == nil {
+		chunks = splitResponse(p.response(e, req, seq))
+	}
+
+	stream := make(chan llm.ChatStreamChunk, len(chunks))
+	for _, chunk := range chunks {
+		stream <- chunk
+	}
+	close(stream)
+	return stream, nil
+}
+
+// Complete 实现补全接口，按单条用户消息的聊天请求匹配脚本
+func (p *MockProvider) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	chatReq := &llm.ChatRequest{
+		Model:       req.Model,
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: req.Prompt}},
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+	}
+	e, seq, err := p.match(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	chatResp := p.response(e, chatReq, seq)
+	resp := &llm.CompletionResponse{
+		ID:      chatResp.ID,
+		Object:  "text_completion",
+		Created: chatResp.Created,
+		Model:   chatResp.Model,
+		Usage:   chatResp.Usage,
+	}
+	for _, choice := range chatResp.Choices {
+		resp.Choices = append(resp.Choices, struct {
+			Text         string      `json:"text"`
+			Index        int         `json:"index"`
+			Logprobs     interface{} `json:"logprobs"`
+			FinishReason string      `json:"finish_reason"`
+		}{Text: choice.Message.Content, Index: choice.Index, FinishReason: choice.FinishReason})
+	}
+	return resp, nil
+}
+
+// GetConfig 获取配置
+func (p *MockProvider) GetConfig() *llm.Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config
+}
+
+// SetConfig 设置配置
+func (p *MockProvider) SetConfig(config *llm.Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = config
+}
+
+// GetModelType 获取模型类型
+func (p *MockProvider) GetModelType() llm.ModelType {
+	return ModelTypeMock
+}
+
+// match 记录请求并查找脚本，按脚本等待延迟后返回脚本或脚本中的错误，seq 为请求的序号（从 1 开始）
+func (p *MockProvider) match(ctx context.Context, req *llm.ChatRequest) (*Expectation, int, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	seq := len(p.requests)
+
+	var matched *Expectation
+	for _, e := range p.expectations {
+		if e.times != 0 && e.match(req) {
+			matched = e
+			break
+		}
+	}
+	if matched != nil {
+		matched.calls++
+		if matched.times > 0 {
+			matched.times--
+		}
+	}
+	p.mu.Unlock()
+
+	if matched == nil {
+		return nil, seq, fmt.Errorf("llmtest: no expectation matches request for model %q: %q", req.Model, lastContent(req))
+	}
+
+	if matched.delay > 0 {
+		timer := time.NewTimer(matched.delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, seq, ctx.Err()
+		}
+	}
+
+	if matched.err != nil {
+		return nil, seq, matched.err
+	}
+	return matched, seq, nil
+}
+
+// response 复制脚本中的响应，并按请求补全候选数、模型名和用量
+func (p *MockProvider) response(e *Expectation, req *llm.ChatRequest, seq int) *llm.ChatResponse {
+	resp := &llm.ChatResponse{}
+	if e.response != nil {
+		*resp = *e.response
+		resp.Choices = append([]llm.ChatChoice(nil), e.response.Choices...)
+	}
+
+	// 请求多个候选时复制单个候选，保证各候选的序号正确
+	if req.N > 1 && len(resp.Choices) == 1 {
+		for i := 1; i < req.N; i++ {
+			choice := resp.Choices[0]
+			choice.Index = i
+			resp.Choices = append(resp.Choices, choice)
+		}
+	}
+
+	if resp.ID == "" {
+		resp.ID = fmt.Sprintf("mock-%d", seq)
+	}
+	if resp.Object == "" {
+		resp.Object = "chat.completion"
+	}
+	if resp.Model == "" {
+		resp.Model = req.Model
+		if resp.Model == "" {
+			resp.Model = p.GetConfig().Model
+		}
+	}
+
+	// 用量按字符估算，保证结果稳定
+	if resp.Usage == (llm.Usage{}) {
+		tokenizer := llm.CharTokenizer{}
+		resp.Usage.PromptTokens = llm.CountMessageTokens(tokenizer, req.Messages)
+		for _, choice := range resp.Choices {
+			resp.Usage.CompletionTokens += tokenizer.Count(choice.Message.Content)
+		}
+		resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+	}
+
+	return resp
+}
+
+// splitResponse 将响应按词切分为流式数据块，最后附加用量数据块
+func splitResponse(resp *llm.ChatResponse) []llm.ChatStreamChunk {
+	var chunks []llm.ChatStreamChunk
+	for _, choice := range resp.Choices {
+		words := strings.SplitAfter(choice.Message.Content, " ")
+		for i, word := range words {
+			chunk := llm.ChatStreamChunk{
+				ID:      resp.ID,
+				Model:   resp.Model,
+				Index:   choice.Index,
+				Content: word,
+			}
+			if i == 0 {
+				chunk.Role = choice.Message.Role
+				chunk.ToolCalls = indexedToolCalls(choice.Message.ToolCalls)
+			}
+			if i == len(words)-1 {
+				chunk.FinishReason = choice.FinishReason
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	usage := resp.Usage
+	chunks = append(chunks, llm.ChatStreamChunk{ID: resp.ID, Model: resp.Model, Usage: &usage})
+	return chunks
+}
+
+// indexedToolCalls 为流式数据块中的工具调用填写序号
+func indexedToolCalls(calls []llm.ToolCall) []llm.ToolCall {
+	var result []llm.ToolCall
+	for i, call := range calls {
+		index := i
+		call.Index = &index
+		result = append(result, call)
+	}
+	return result
+}
+
+// lastContent 返回最后一条消息的内容，用于错误信息
+func lastContent(req *llm.ChatRequest) string {
+	if len(req.Messages) == 0 {
+		return ""
+	}
+	return req.Messages[len(req.Messages)-1].Content
+}
@@ -0,0 +1,183 @@
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-llm-tools/internal/llm"
+)
+
+func chatRequest(model, content string) *llm.ChatRequest {
+	return &llm.ChatRequest{Model: model, Messages: []llm.Message{{Role: llm.RoleUser, Content: content}}}
+}
+
+func TestMockProviderMatchesInOrder(t *testing.T) {
+	mock := NewMockProvider(nil)
+	first := mock.On(Contains("hello")).Reply("hi").Once()
+	mock.On(ModelIs("gpt-4o")).Reply("from gpt-4o")
+	mock.On(Any()).Reply("fallback")
+
+	tests := []struct {
+		req  *llm.ChatRequest
+		want string
+	}{
+		{chatRequest("gpt-4o", "hello there"), "hi"},
+		{chatRequest("gpt-4o", "hello again"), "from gpt-4o"},
+		{chatRequest("other", "hello"), "fallback"},
+	}
+	for _, tt := range tests {
+		resp, err := mock.Chat(context.Background(), tt.req)
+		if err != nil {
+			t.Fatalf("Chat(%q) error: %v", tt.req.Messages[0].Content, err)
+		}
+		if got := resp.Choices[0].Message.Content; got != tt.want {
+			t.Errorf("Chat(%q) = %q, want %q", tt.req.Messages[0].Content, got, tt.want)
+		}
+	}
+
+	if first.Calls() != 1 {
+		t.Errorf("Once expectation calls = %d, want 1", first.Calls())
+	}
+	if got := len(mock.Requests()); got != len(tests) {
+		t.Errorf("Requests() = %d, want %d", got, len(tests))
+	}
+}
+
+func TestMockProviderFillsResponse(t *testing.T) {
+	mock := NewMockProvider(nil)
+	mock.On(Any()).Reply("four words in reply")
+
+	req := chatRequest("", "question")
+	req.N = 2
+	resp, err := mock.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+
+	if resp.Model != "mock-model" {
+		t.Errorf("Model = %q, want config model", resp.Model)
+	}
+	if len(resp.Choices) != 2 || resp.Choices[1].Index != 1 {
+		t.Errorf("Choices = %+v, want 2 indexed choices", resp.Choices)
+	}
+	if resp.Usage.PromptTokens == 0 || resp.Usage.TotalTokens != resp.Usage.PromptTokens+resp.Usage.CompletionTokens {
+		t.Errorf("Usage = %+v, want estimated usage", resp.Usage)
+	}
+}
+
+func TestMockProviderNoMatch(t *testing.T) {
+	mock := NewMockProvider(nil)
+	mock.On(ModelIs("gpt-4o")).Reply("hi")
+
+	_, err := mock.Chat(context.Background(), chatRequest("claude-3-haiku", "hello"))
+	if err == nil || !strings.Contains(err.Error(), "no expectation matches") {
+		t.Fatalf("Chat error = %v, want no expectation error", err)
+	}
+}
+
+func TestMockProviderFail(t *testing.T) {
+	mock := NewMockProvider(nil)
+	mock.On(Any()).Fail(&llm.ProviderError{Kind: llm.ErrRateLimited, StatusCode: 429, RetryAfter: 2 * time.Second, Err: errors.New("slow down")}).Once()
+	mock.On(Any()).Reply("ok")
+
+	_, err := mock.Chat(context.Background(), chatRequest("", "hello"))
+	if !errors.Is(err, llm.ErrRateLimited) {
+		t.Fatalf("first Chat error = %v, want ErrRateLimited", err)
+	}
+	if got := llm.RetryAfter(err); got != 2*time.Second {
+		t.Errorf("RetryAfter = %v, want 2s", got)
+	}
+
+	if _, err := mock.Chat(context.Background(), chatRequest("", "hello")); err != nil {
+		t.Fatalf("second Chat error: %v", err)
+	}
+
+	// 建立流时同样返回错误
+	mock = NewMockProvider(nil)
+	mock.On(Any()).Fail(llm.ErrUnavailable)
+	if _, err := mock.ChatStream(context.Background(), chatRequest("", "hello")); !errors.Is(err, llm.ErrUnavailable) {
+		t.Errorf("ChatStream error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestMockProviderDelay(t *testing.T) {
+	mock := NewMockProvider(nil)
+	mock.On(Any()).Reply("late").Delay(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := mock.Chat(context.Background(), chatRequest("", "hello")); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Chat returned after %v, want at least 50ms", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := mock.Chat(ctx, chatRequest("", "hello")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Chat error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestMockProviderStream(t *testing.T) {
+	mock := NewMockProvider(nil)
+	mock.On(Any()).Reply("streamed reply text")
+
+	stream, err := mock.ChatStream(context.Background(), chatRequest("", "hello"))
+	if err != nil {
+		t.Fatalf("ChatStream error: %v", err)
+	}
+
+	var content strings.Builder
+	var usage *llm.Usage
+	var finish string
+	for chunk := range stream {
+		content.WriteString(chunk.Content)
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if content.String() != "streamed reply text" {
+		t.Errorf("stream content = %q", content.String())
+	}
+	if finish != "stop" {
+		t.Errorf("finish reason = %q, want stop", finish)
+	}
+	if usage == nil || usage.TotalTokens == 0 {
+		t.Errorf("usage = %+v, want final usage chunk", usage)
+	}
+
+	mock = NewMockProvider(nil)
+	mock.On(Any()).StreamChunks(llm.ChatStreamChunk{Content: "a"}, llm.ChatStreamChunk{Err: llm.ErrTimeout})
+	stream, err = mock.ChatStream(context.Background(), chatRequest("", "hello"))
+	if err != nil {
+		t.Fatalf("ChatStream error: %v", err)
+	}
+	var last llm.ChatStreamChunk
+	for chunk := range stream {
+		last = chunk
+	}
+	if !errors.Is(last.Err, llm.ErrTimeout) {
+		t.Errorf("last chunk error = %v, want ErrTimeout", last.Err)
+	}
+}
+
+func TestMockProviderComplete(t *testing.T) {
+	mock := NewMockProvider(nil)
+	mock.On(Contains("prompt")).Reply("completed")
+
+	resp, err := mock.Complete(context.Background(), &llm.CompletionRequest{Prompt: "a prompt"})
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Text != "completed" {
+		t.Errorf("Complete choices = %+v", resp.Choices)
+	}
+}
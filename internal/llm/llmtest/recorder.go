@@ -0,0 +1,306 @@
+package llmtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-llm-tools/internal/llm"
+)
+
+// Mode 录制回放模式
+type Mode string
+
+const (
+	// ModeReplay 只回放 golden 文件，缺少文件时返回错误，不访问网络
+	ModeReplay Mode = "replay"
+	// ModeRecord 总是调用上游并覆盖 golden 文件
+	ModeRecord Mode = "record"
+	// ModeRecordMissing 有 golden 文件时回放，否则调用上游并录制
+	ModeRecordMissing Mode = "missing"
+)
+
+// ModeFromEnv 从环境变量 LLMTEST_MODE 读取模式，未设置或无法识别时为 ModeReplay
+func ModeFromEnv() Mode {
+	switch mode := Mode(os.Getenv("LLMTEST_MODE")); mode {
+	case ModeRecord, ModeRecordMissing:
+		return mode
+	default:
+		return ModeReplay
+	}
+}
+
+// errorKinds golden 文件中的错误分类名
+var errorKinds = map[string]error{
+	"rate_limited":     llm.ErrRateLimited,
+	"auth":             llm.ErrAuth,
+	"context_length":   llm.ErrContextLength,
+	"content_filtered": llm.ErrContentFiltered,
+	"timeout":          llm.ErrTimeout,
+	"unavailable":      llm.ErrUnavailable,
+}
+
+// goldenError 录制的错误，回放时还原为同一分类的 *llm.ProviderError
+type goldenError struct {
+	Kind       string        `json:"kind,omitempty"`
+	Message    string        `json:"message"`
+	StatusCode int           `json:"status_code,omitempty"`
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+
+	// canceled 调用被调用方取消（包括包装过的 context.Canceled），只在录制时使用
+	canceled bool
+}
+
+// goldenChunk 录制的流式数据块
+type goldenChunk struct {
+	llm.ChatStreamChunk
+	Error *goldenError `json:"error,omitempty"`
+}
+
+// golden 一次调用的录制内容
+type golden struct {
+	ChatRequest        *llm.ChatRequest        `json:"chat_request,omitempty"`
+	CompletionRequest  *llm.CompletionRequest  `json:"completion_request,omitempty"`
+	ChatResponse       *llm.ChatResponse       `json:"chat_response,omitempty"`
+	CompletionResponse *llm.CompletionResponse `json:"completion_response,omitempty"`
+	Chunks             []goldenChunk           `json:"chunks,omitempty"`
+	Error              *goldenError            `json:"error,omitempty"`
+}
+
+// recordedError 回放的错误，错误信息与录制时相同，errors.Is 和 llm.RetryAfter 的结果也相同
+type recordedError struct {
+	message string
+	err     error
+}
+
+func (e *recordedError) Error() string { return e.message }
+func (e *recordedError) Unwrap() error { return e.err }
+
+// Recorder 录制回放提供者
+//
+// 每次调用按请求内容（模型、消息、采样参数、工具）的哈希对应 dir 下的一个 golden 文件。
+// 录制时调用上游提供者并保存响应、流式数据块或错误；回放时只读取文件，不访问网络。
+type Recorder struct {
+	upstream llm.Provider
+	dir      string
+	mode     Mode
+}
+
+// NewRecorder 创建录制回放提供者，ModeReplay 下 upstream 可以为 nil
+func NewRecorder(upstream llm.Provider, dir string, mode Mode) *Recorder {
+	return &Recorder{upstream: upstream, dir: dir, mode: mode}
+}
+
+// Chat 实现聊天接口
+func (r *Recorder) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	g, err := r.load("chat", req, func() *golden {
+		resp, err := r.upstream.Chat(ctx, req)
+		return &golden{ChatRequest: req, ChatResponse: resp, Error: encodeError(err)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if g.Error != nil {
+		return nil, decodeError(g.Error)
+	}
+	return g.ChatResponse, nil
+}
+
+// ChatStream 实现流式聊天接口，录制时先读完上游的流再回放给调用方
+func (r *Recorder) ChatStream(ctx context.Context, req *llm.ChatRequest) (<-chan llm.ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	g, err := r.load("chat_stream", req, func() *golden {
+		stream, err := r.upstream.ChatStream(ctx, req)
+		if err != nil {
+			return &golden{ChatRequest: req, Error: encodeError(err)}
+		}
+
+		g := &golden{ChatRequest: req}
+		for chunk := range stream {
+			g.Chunks = append(g.Chunks, goldenChunk{ChatStreamChunk: chunk, Error: encodeError(chunk.Err)})
+		}
+		return g
+	})
+	if err != nil {
+		return nil, err
+	}
+	if g.Error != nil {
+		return nil, decodeError(g.Error)
+	}
+
+	chunks := make(chan llm.ChatStreamChunk, len(g.Chunks))
+	for _, chunk := range g.Chunks {
+		if chunk.Error != nil {
+			chunk.Err = decodeError(chunk.Error)
+		}
+		chunks <- chunk.ChatStreamChunk
+	}
+	close(chunks)
+	return chunks, nil
+}
+
+// Complete 实现补全接口
+func (r *Recorder) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	g, err := r.load("completion", req, func() *golden {
+		resp, err := r.upstream.Complete(ctx, req)
+		return &golden{CompletionRequest: req, CompletionResponse: resp, Error: encodeError(err)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if g.Error != nil {
+		return nil, decodeError(g.Error)
+	}
+	return g.CompletionResponse, nil
+}
+
+// GetConfig 获取上游提供者的配置，没有上游时返回测试用的默认配置
+func (r *Recorder) GetConfig() *llm.Config {
+	if r.upstream == nil {
+		return &llm.Config{Model: "mock-model", Temperature: 0.7, MaxTokens: 1000}
+	}
+	return r.upstream.GetConfig()
+}
+
+// SetConfig 设置上游提供者的配置
+func (r *Recorder) SetConfig(config *llm.Config) {
+	if r.upstream != nil {
+		r.upstream.SetConfig(config)
+	}
+}
+
+// GetModelType 获取上游提供者的模型类型，没有上游时为 ModelTypeMock
+func (r *Recorder) GetModelType() llm.ModelType {
+	if r.upstream == nil {
+		return ModelTypeMock
+	}
+	return r.upstream.GetModelType()
+}
+
+// load 按模式读取或录制 golden 文件
+func (r *Recorder) load(kind string, req interface{}, record func() *golden) (*golden, error) {
+	path, err := r.path(kind, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode != ModeRecord {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var g golden
+			if err := json.Unmarshal(data, &g); err != nil {
+				return nil, fmt.Errorf("llmtest: invalid golden file %s: %w", path, err)
+			}
+			return &g, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("llmtest: failed to read golden file %s: %w", path, err)
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("llmtest: golden file %s not found, run with LLMTEST_MODE=%s to record it", path, ModeRecordMissing)
+		}
+	}
+
+	if r.upstream == nil {
+		return nil, fmt.Errorf("llmtest: recording %s requires an upstream provider", path)
+	}
+
+	g := record()
+	// 调用方取消的调用不代表上游的真实行为，不保存
+	if g.canceled() {
+		return g, nil
+	}
+
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("llmtest: failed to encode golden file: %w", err)
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("llmtest: failed to create golden dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("llmtest: failed to write golden file: %w", err)
+	}
+	return g, nil
+}
+
+// canceled 判断录制的调用或流是否被调用方取消
+func (g *golden) canceled() bool {
+	if g.Error != nil && g.Error.canceled {
+		return true
+	}
+	for _, chunk := range g.Chunks {
+		if chunk.Error != nil && chunk.Error.canceled {
+			return true
+		}
+	}
+	return false
+}
+
+// path 返回请求对应的 golden 文件路径
+func (r *Recorder) path(kind string, req interface{}) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("llmtest: failed to encode request: %w", err)
+	}
+
+	sum := sha256.Sum256(append([]byte(kind+"\x00"), data...))
+	return filepath.Join(r.dir, kind+"-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// encodeError 转换为可保存的错误
+func encodeError(err error) *goldenError {
+	if err == nil {
+		return nil
+	}
+
+	g := &goldenError{Message: err.Error(), RetryAfter: llm.RetryAfter(err), canceled: errors.Is(err, context.Canceled)}
+	var providerErr *llm.ProviderError
+	if errors.As(err, &providerErr) {
+		g.StatusCode = providerErr.StatusCode
+	}
+	for name, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			g.Kind = name
+			break
+		}
+	}
+	return g
+}
+
+// decodeError 还原录制的错误
+func decodeError(g *goldenError) error {
+	if g.canceled {
+		return &recordedError{message: g.Message, err: context.Canceled}
+	}
+
+	kind, ok := errorKinds[g.Kind]
+	if !ok {
+		if g.Message == context.Canceled.Error() {
+			return context.Canceled
+		}
+		return errors.New(g.Message)
+	}
+
+	return &recordedError{
+		message: g.Message,
+		err:     &llm.ProviderError{Kind: kind, StatusCode: g.StatusCode, RetryAfter: g.RetryAfter, Err: errors.New(g.Message)},
+	}
+}
@@ -0,0 +1,155 @@
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-llm-tools/internal/llm"
+)
+
+// goldenFiles 返回目录下的 golden 文件
+func goldenFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatalf("Glob error: %v", err)
+	}
+	return files
+}
+
+func TestRecorderRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	upstream := NewMockProvider(nil)
+	upstream.On(Any()).Reply("recorded answer")
+
+	req := chatRequest("gpt-4o", "question")
+	recorded, err := NewRecorder(upstream, dir, ModeRecord).Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("record Chat error: %v", err)
+	}
+	if files := goldenFiles(t, dir); len(files) != 1 || !strings.HasPrefix(filepath.Base(files[0]), "chat-") {
+		t.Fatalf("golden files = %v, want one chat file", files)
+	}
+
+	// 回放不需要上游
+	replayed, err := NewRecorder(nil, dir, ModeReplay).Chat(context.Background(), chatRequest("gpt-4o", "question"))
+	if err != nil {
+		t.Fatalf("replay Chat error: %v", err)
+	}
+	if replayed.Choices[0].Message.Content != recorded.Choices[0].Message.Content || replayed.Usage != recorded.Usage {
+		t.Errorf("replayed = %+v, want %+v", replayed, recorded)
+	}
+
+	// 请求不同时没有对应的 golden 文件
+	_, err = NewRecorder(nil, dir, ModeReplay).Chat(context.Background(), chatRequest("gpt-4o", "another question"))
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("replay missing error = %v, want not found", err)
+	}
+}
+
+func TestRecorderRecordMissing(t *testing.T) {
+	dir := t.TempDir()
+	upstream := NewMockProvider(nil)
+	e := upstream.On(Any()).Reply("once")
+
+	recorder := NewRecorder(upstream, dir, ModeRecordMissing)
+	for i := 0; i < 2; i++ {
+		if _, err := recorder.Chat(context.Background(), chatRequest("", "question")); err != nil {
+			t.Fatalf("Chat %d error: %v", i, err)
+		}
+	}
+	if e.Calls() != 1 {
+		t.Errorf("upstream calls = %d, want 1", e.Calls())
+	}
+}
+
+func TestRecorderReplaysErrors(t *testing.T) {
+	dir := t.TempDir()
+	upstream := NewMockProvider(nil)
+	upstream.On(Any()).Fail(&llm.ProviderError{Kind: llm.ErrRateLimited, StatusCode: 429, RetryAfter: 3 * time.Second, Err: errors.New("quota exceeded")})
+
+	_, recordErr := NewRecorder(upstream, dir, ModeRecord).Chat(context.Background(), chatRequest("", "question"))
+	if recordErr == nil {
+		t.Fatal("record Chat error = nil")
+	}
+
+	_, err := NewRecorder(nil, dir, ModeReplay).Chat(context.Background(), chatRequest("", "question"))
+	if !errors.Is(err, llm.ErrRateLimited) {
+		t.Fatalf("replay error = %v, want ErrRateLimited", err)
+	}
+	if err.Error() != recordErr.Error() {
+		t.Errorf("replay message = %q, want %q", err.Error(), recordErr.Error())
+	}
+	if got := llm.RetryAfter(err); got != 3*time.Second {
+		t.Errorf("RetryAfter = %v, want 3s", got)
+	}
+	var providerErr *llm.ProviderError
+	if !errors.As(err, &providerErr) || providerErr.StatusCode != 429 {
+		t.Errorf("replay ProviderError = %+v, want status 429", providerErr)
+	}
+}
+
+func TestRecorderStreamAndComplete(t *testing.T) {
+	dir := t.TempDir()
+	upstream := NewMockProvider(nil)
+	upstream.On(Any()).Reply("streamed words")
+
+	record := NewRecorder(upstream, dir, ModeRecord)
+	stream, err := record.ChatStream(context.Background(), chatRequest("", "question"))
+	if err != nil {
+		t.Fatalf("record ChatStream error: %v", err)
+	}
+	for range stream {
+	}
+	if _, err := record.Complete(context.Background(), &llm.CompletionRequest{Prompt: "question"}); err != nil {
+		t.Fatalf("record Complete error: %v", err)
+	}
+
+	replay := NewRecorder(nil, dir, ModeReplay)
+	stream, err = replay.ChatStream(context.Background(), chatRequest("", "question"))
+	if err != nil {
+		t.Fatalf("replay ChatStream error: %v", err)
+	}
+	var content strings.Builder
+	for chunk := range stream {
+		content.WriteString(chunk.Content)
+	}
+	if content.String() != "streamed words" {
+		t.Errorf("replayed stream = %q", content.String())
+	}
+
+	resp, err := replay.Complete(context.Background(), &llm.CompletionRequest{Prompt: "question"})
+	if err != nil {
+		t.Fatalf("replay Complete error: %v", err)
+	}
+	if resp.Choices[0].Text != "streamed words" {
+		t.Errorf("replayed completion = %q", resp.Choices[0].Text)
+	}
+}
+
+func TestRecorderSkipsCanceled(t *testing.T) {
+	dir := t.TempDir()
+	upstream := NewMockProvider(nil)
+	upstream.On(Contains("wrapped")).Fail(fmt.Errorf("request aborted: %w", context.Canceled))
+	upstream.On(Contains("stream")).StreamChunks(llm.ChatStreamChunk{Content: "partial"}, llm.ChatStreamChunk{Err: fmt.Errorf("read body: %w", context.Canceled)})
+
+	recorder := NewRecorder(upstream, dir, ModeRecord)
+	if _, err := recorder.Chat(context.Background(), chatRequest("", "wrapped")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Chat error = %v, want context.Canceled", err)
+	}
+	stream, err := recorder.ChatStream(context.Background(), chatRequest("", "stream"))
+	if err != nil {
+		t.Fatalf("ChatStream error: %v", err)
+	}
+	for range stream {
+	}
+
+	if files := goldenFiles(t, dir); len(files) != 0 {
+		t.Errorf("golden files = %v, want none for canceled calls", files)
+	}
+}
@@ -0,0 +1,547 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const localDefaultBaseURL = "http://localhost:11434"
+
+// LocalProvider 本地模型提供者，兼容 Ollama 的 /api/chat 与 /api/generate 接口
+type LocalProvider struct {
+	client *http.Client
+	config *Config
+
+	// AutoPull 为 true 时，请求的模型未安装会先自动拉取再重试
+	AutoPull bool
+}
+
+// LocalModel 本地已安装的模型
+type LocalModel struct {
+	Name       string    `json:"name"`
+	Model      string    `json:"model"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Size       int64     `json:"size"`
+	Digest     string    `json:"digest"`
+}
+
+// PullProgress 拉取模型的进度
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type localOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             float64  `json:"top_p,omitempty"`
+	NumPredict       int      `json:"num_predict,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"`
+}
+
+type localMessage struct {
+	Role      string          `json:"role"`
+	Content   string          `json:"content"`
+	Images    []string        `json:"images,omitempty"`
+	ToolCalls []localToolCall `json:"tool_calls,omitempty"`
+}
+
+// localToolCall Ollama 的工具调用，arguments 为 JSON 对象而非字符串
+type localToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type localChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []localMessage `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Tools    []Tool         `json:"tools,omitempty"`
+	Options  localOptions   `json:"options"`
+
+	// Format 为 "json" 或 JSON Schema（Ollama 0.5 及之后的版本）
+	Format interface{} `json:"format,omitempty"`
+}
+
+type localGenerateRequest struct {
+	Model   string       `json:"model"`
+	Prompt  string       `json:"prompt"`
+	Stream  bool         `json:"stream"`
+	Options localOptions `json:"options"`
+}
+
+// localResponse /api/chat 与 /api/generate 共用的响应结构
+type localResponse struct {
+	Model           string       `json:"model"`
+	CreatedAt       time.Time    `json:"created_at"`
+	Message         localMessage `json:"message"`
+	Response        string       `json:"response"`
+	Done            bool         `json:"done"`
+	DoneReason      string       `json:"done_reason"`
+	PromptEvalCount int          `json:"prompt_eval_count"`
+	EvalCount       int          `json:"eval_count"`
+	Error           string       `json:"error"`
+}
+
+// NewLocalProvider 创建本地模型提供者
+func NewLocalProvider(config *Config) *LocalProvider {
+	if config == nil {
+		config = &Config{
+			BaseURL:     localDefaultBaseURL,
+			Model:       "qwen2",
+			Timeout:     120 * time.Second,
+			MaxRetries:  3,
+			Temperature: 0.7,
+			MaxTokens:   1000,
+		}
+	}
+
+	return &LocalProvider{
+		client:   newHTTPClient(),
+		config:   config,
+		AutoPull: config.AutoPull,
+	}
+}
+
+// Chat 实现聊天接口
+func (p *LocalProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	localReq, err := p.buildChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		resp     localResponse
+		attempts int
+	)
+	err = p.withAutoPull(ctx, localReq.Model, func() error {
+		var err error
+		attempts, err = withRetry(ctx, p.config, func(ctx context.Context) error {
+			return doJSON(ctx, p.client, http.MethodPost, p.url("/api/chat"), nil, localReq, &resp)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local chat completion failed: %w", err)
+	}
+
+	message := Message{
+		Role:      resp.Message.Role,
+		Content:   resp.Message.Content,
+		ToolCalls: fromLocalToolCalls(resp.Message.ToolCalls),
+	}
+
+	return &ChatResponse{
+		ID:      fmt.Sprintf("local-%d", resp.CreatedAt.UnixNano()),
+		Object:  "chat.completion",
+		Created: resp.CreatedAt.Unix(),
+		Model:   resp.Model,
+		Choices: []ChatChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: localFinishReason(resp.DoneReason, len(message.ToolCalls) > 0),
+		}},
+		Usage:    localUsage(resp),
+		Attempts: attempts,
+	}, nil
+}
+
+// ChatStream 实现流式聊天接口，Ollama 以 NDJSON 逐行返回增量内容
+func (p *LocalProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	localReq, err := p.buildChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	localReq.Stream = true
+
+	var (
+		resp    *http.Response
+		release context.CancelFunc
+	)
+	err = p.withAutoPull(ctx, localReq.Model, func() error {
+		var err error
+		_, release, err = withRetryStream(ctx, p.config, func(ctx context.Context) error {
+			var err error
+			resp, err = sendRequest(ctx, p.client, http.MethodPost, p.url("/api/chat"), nil, localReq)
+			return err
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local chat stream failed: %w", err)
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+		defer release()
+		defer resp.Body.Close()
+
+		id := fmt.Sprintf("local-%d", time.Now().UnixNano())
+		toolIndex := 0
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+
+			var event localResponse
+			if err := json.Unmarshal(line, &event); err != nil {
+				sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("local chat stream failed: %w", err)})
+				return
+			}
+			if event.Error != "" {
+				sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("local chat stream failed: %w", streamError("", event.Error))})
+				return
+			}
+
+			chunk := ChatStreamChunk{
+				ID:      id,
+				Model:   event.Model,
+				Role:    event.Message.Role,
+				Content: event.Message.Content,
+			}
+			for _, call := range fromLocalToolCalls(event.Message.ToolCalls) {
+				index := toolIndex
+				toolIndex++
+				call.Index = &index
+				call.ID = fmt.Sprintf("call_%d", index)
+				chunk.ToolCalls = append(chunk.ToolCalls, call)
+			}
+			if event.Done {
+				chunk.FinishReason = localFinishReason(event.DoneReason, toolIndex > 0)
+			}
+			if !sendChunk(ctx, chunks, chunk) {
+				return
+			}
+
+			if event.Done {
+				usage := localUsage(event)
+				sendChunk(ctx, chunks, ChatStreamChunk{ID: id, Model: event.Model, Usage: &usage})
+				return
+			}
+		}
+
+		// 连接在 done 之前断开时回答不完整，按服务不可用返回以便重试
+		err := scanner.Err()
+		if err == nil && ctx.Err() == nil {
+			err = fmt.Errorf("unexpected end of stream: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("local chat stream failed: %w", classifyError(err))})
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口，对应 /api/generate
+func (p *LocalProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	generateReq := localGenerateRequest{
+		Model:   req.Model,
+		Prompt:  req.Prompt,
+		Options: p.buildOptions(req.Temperature, req.TopP, req.MaxTokens, req.Stop),
+	}
+	if generateReq.Model == "" {
+		generateReq.Model = p.config.Model
+	}
+
+	var (
+		resp     localResponse
+		attempts int
+	)
+	err := p.withAutoPull(ctx, generateReq.Model, func() error {
+		var err error
+		attempts, err = withRetry(ctx, p.config, func(ctx context.Context) error {
+			return doJSON(ctx, p.client, http.MethodPost, p.url("/api/generate"), nil, generateReq, &resp)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local completion failed: %w", err)
+	}
+
+	completionResp := &CompletionResponse{
+		ID:       fmt.Sprintf("local-%d", resp.CreatedAt.UnixNano()),
+		Object:   "text_completion",
+		Created:  resp.CreatedAt.Unix(),
+		Model:    resp.Model,
+		Usage:    localUsage(resp),
+		Attempts: attempts,
+	}
+	completionResp.Choices = make([]struct {
+		Text         string      `json:"text"`
+		Index        int         `json:"index"`
+		Logprobs     interface{} `json:"logprobs"`
+		FinishReason string      `json:"finish_reason"`
+	}, 1)
+	completionResp.Choices[0].Text = resp.Response
+	completionResp.Choices[0].FinishReason = localFinishReason(resp.DoneReason, false)
+
+	return completionResp, nil
+}
+
+// ListModels 列出本地已安装的模型
+func (p *LocalProvider) ListModels(ctx context.Context) ([]LocalModel, error) {
+	var resp struct {
+		Models []LocalModel `json:"models"`
+	}
+	if err := doJSON(ctx, p.client, http.MethodGet, p.url("/api/tags"), nil, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list local models: %w", err)
+	}
+
+	return resp.Models, nil
+}
+
+// PullModel 拉取模型，progress 不为空时会收到拉取进度
+func (p *LocalProvider) PullModel(ctx context.Context, name string, progress func(PullProgress)) error {
+	body := map[string]interface{}{"model": name, "stream": true}
+	resp, err := sendRequest(ctx, p.client, http.MethodPost, p.url("/api/pull"), nil, body)
+	if err != nil {
+		return fmt.Errorf("failed to pull model %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event PullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("failed to pull model %s: %w", name, err)
+		}
+		if event.Error != "" {
+			return fmt.Errorf("failed to pull model %s: %s", name, event.Error)
+		}
+		if progress != nil {
+			progress(event)
+		}
+		if event.Status == "success" {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to pull model %s: %w", name, err)
+	}
+
+	return fmt.Errorf("failed to pull model %s: stream ended unexpectedly", name)
+}
+
+// EnsureModel 确保模型已安装，未安装时自动拉取
+func (p *LocalProvider) EnsureModel(ctx context.Context, name string) error {
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, model := range models {
+		if model.Name == name || model.Model == name || strings.TrimSuffix(model.Name, ":latest") == name {
+			return nil
+		}
+	}
+
+	return p.PullModel(ctx, name, nil)
+}
+
+// GetConfig 获取配置
+func (p *LocalProvider) GetConfig() *Config {
+	return p.config
+}
+
+// SetConfig 设置配置，同时按配置更新 AutoPull
+func (p *LocalProvider) SetConfig(config *Config) {
+	p.config = config
+	if config != nil {
+		p.AutoPull = config.AutoPull
+	}
+}
+
+// GetModelType 获取模型类型
+func (p *LocalProvider) GetModelType() ModelType {
+	return ModelTypeLocal
+}
+
+// withAutoPull 执行调用，模型未安装且开启 AutoPull 时拉取模型后重试一次
+func (p *LocalProvider) withAutoPull(ctx context.Context, model string, call func() error) error {
+	err := call()
+
+	var httpErr *HTTPError
+	if !p.AutoPull || !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		return err
+	}
+
+	if pullErr := p.PullModel(ctx, model, nil); pullErr != nil {
+		return pullErr
+	}
+
+	return call()
+}
+
+// buildChatRequest 将通用聊天请求转换为 Ollama 请求
+func (p *LocalProvider) buildChatRequest(req *ChatRequest) (*localChatRequest, error) {
+	// user 仅用于上游的滥用监测，本地模型直接忽略
+	if err := checkParams("local", req, ParamStop, ParamSeed, ParamPresencePenalty, ParamFrequencyPenalty, ParamUser); err != nil {
+		return nil, err
+	}
+
+	localReq := &localChatRequest{
+		Model:   req.Model,
+		Options: p.buildOptions(req.Temperature, req.TopP, req.MaxTokens, req.Stop),
+	}
+	localReq.Options.Seed = req.Seed
+	localReq.Options.PresencePenalty = req.PresencePenalty
+	localReq.Options.FrequencyPenalty = req.FrequencyPenalty
+	if localReq.Model == "" {
+		localReq.Model = p.config.Model
+	}
+	if req.ToolChoice != ToolChoiceNone {
+		localReq.Tools = req.Tools
+	}
+	if format := req.ResponseFormat; format != nil {
+		switch {
+		case format.Type == ResponseFormatJSONSchema && format.Schema != nil:
+			localReq.Format = format.Schema
+		case format.Type == ResponseFormatJSONObject || format.Type == ResponseFormatJSONSchema:
+			localReq.Format = "json"
+		}
+	}
+
+	for _, msg := range req.Messages {
+		message := localMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		// Ollama 的图片为 base64 数据列表，是否支持取决于模型（如 llava）
+		for _, part := range msg.Parts {
+			if part.Type == ContentPartImage {
+				_, data, ok := part.imageData()
+				if !ok {
+					return nil, fmt.Errorf("%w: local provider requires base64 image data, not image URLs", ErrUnsupportedContent)
+				}
+				message.Images = append(message.Images, data)
+				continue
+			}
+
+			text, ok := part.inlineText()
+			if !ok {
+				return nil, unsupportedPart("local", part)
+			}
+			if message.Content != "" {
+				message.Content += "\n\n"
+			}
+			message.Content += text
+		}
+		for _, call := range msg.ToolCalls {
+			var toolCall localToolCall
+			toolCall.Function.Name = call.Function.Name
+			toolCall.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if len(toolCall.Function.Arguments) == 0 {
+				toolCall.Function.Arguments = json.RawMessage("{}")
+			}
+			if !json.Valid(toolCall.Function.Arguments) {
+				return nil, fmt.Errorf("invalid arguments for tool call %s", call.ID)
+			}
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		}
+		localReq.Messages = append(localReq.Messages, message)
+	}
+
+	return localReq, nil
+}
+
+// buildOptions 构建模型参数
+func (p *LocalProvider) buildOptions(temperature, topP float64, maxTokens int, stop []string) localOptions {
+	options := localOptions{
+		TopP:       topP,
+		NumPredict: maxTokens,
+		Stop:       stop,
+	}
+	// temperature 为 0 是合法取值（贪心解码），总是发送，避免使用模型自带的默认温度
+	if temperature < 0 {
+		temperature = 0
+	}
+	options.Temperature = &temperature
+	return options
+}
+
+// url 拼接接口地址
+func (p *LocalProvider) url(path string) string {
+	baseURL := p.config.BaseURL
+	if baseURL == "" {
+		baseURL = localDefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + path
+}
+
+// fromLocalToolCalls 转换 Ollama 工具调用，Ollama 不返回调用 ID，这里按序号生成
+func fromLocalToolCalls(calls []localToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	result := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		result[i] = ToolCall{
+			ID:   fmt.Sprintf("call_%d", i),
+			Type: ToolTypeFunction,
+			Function: FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: string(call.Function.Arguments),
+			},
+		}
+	}
+
+	return result
+}
+
+// localFinishReason 转换 Ollama 的 done_reason
+func localFinishReason(reason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	if reason == "" {
+		return "stop"
+	}
+	return reason
+}
+
+// localUsage 从 Ollama 响应中提取 Token 使用统计
+func localUsage(resp localResponse) Usage {
+	return Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+}
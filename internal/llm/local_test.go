@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocalChatSendsZeroTemperature(t *testing.T) {
+	var got struct {
+		Options map[string]interface{} `json:"options"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("invalid request body %s: %v", data, err)
+		}
+		io.WriteString(w, `{"model":"qwen2","message":{"role":"assistant","content":"ok"},"done":true,"done_reason":"stop"}`)
+	}))
+	defer server.Close()
+
+	p := NewLocalProvider(&Config{BaseURL: server.URL, Model: "qwen2", Timeout: 5 * time.Second})
+	if _, err := p.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}}); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+
+	if temperature, ok := got.Options["temperature"]; !ok || temperature != float64(0) {
+		t.Errorf("options.temperature = %v (sent %v), want explicit 0", temperature, ok)
+	}
+}
+
+func TestLocalSetConfigUpdatesAutoPull(t *testing.T) {
+	var pulls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/pull":
+			atomic.AddInt32(&pulls, 1)
+			io.WriteString(w, `{"status":"success"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":"model 'qwen2' not found"}`)
+		}
+	}))
+	defer server.Close()
+
+	p := NewLocalProvider(&Config{BaseURL: server.URL, Model: "qwen2", Timeout: 5 * time.Second})
+	if p.AutoPull {
+		t.Fatal("AutoPull = true, want false from config")
+	}
+
+	p.SetConfig(&Config{BaseURL: server.URL, Model: "qwen2", Timeout: 5 * time.Second, AutoPull: true})
+	if !p.AutoPull {
+		t.Fatal("AutoPull = false after SetConfig, want true")
+	}
+
+	p.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	if atomic.LoadInt32(&pulls) != 1 {
+		t.Errorf("pulls = %d, want 1", pulls)
+	}
+}
+
+func TestLocalChatStreamTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"model":"qwen2","message":{"role":"assistant","content":"Hel"},"done":false}`+"\n")
+	}))
+	defer server.Close()
+
+	p := NewLocalProvider(&Config{BaseURL: server.URL, Model: "qwen2", Timeout: 5 * time.Second})
+	stream, err := p.ChatStream(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	if err != nil {
+		t.Fatalf("ChatStream error: %v", err)
+	}
+
+	var (
+		content string
+		last    ChatStreamChunk
+	)
+	for chunk := range stream {
+		content += chunk.Content
+		last = chunk
+	}
+	if content != "Hel" {
+		t.Errorf("content = %q, want partial content before the error", content)
+	}
+	if !errors.Is(last.Err, io.ErrUnexpectedEOF) || !IsRetryable(last.Err) {
+		t.Errorf("last chunk error = %v, want retryable unexpected end of stream", last.Err)
+	}
+}
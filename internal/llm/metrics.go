@@ -0,0 +1,271 @@
+package llm
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets 延迟直方图的默认分桶上界
+var DefaultLatencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+}
+
+// LatencyHistogram 按固定分桶统计延迟的直方图，可并发使用
+type LatencyHistogram struct {
+	bounds []time.Duration
+
+	mu     sync.Mutex
+	counts []uint64 // 最后一个桶统计超过所有上界的样本
+	count  uint64
+	errors uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// HistogramBucket 直方图的一个分桶，Count 为耗时不超过 LE 的累计样本数
+type HistogramBucket struct {
+	LE    float64 `json:"le_ms"`
+	Count uint64  `json:"count"`
+}
+
+// HistogramSnapshot 直方图的统计快照，耗时单位为毫秒
+type HistogramSnapshot struct {
+	Count   uint64            `json:"count"`
+	Errors  uint64            `json:"errors"`
+	Mean    float64           `json:"mean_ms"`
+	Max     float64           `json:"max_ms"`
+	P50     float64           `json:"p50_ms"`
+	P95     float64           `json:"p95_ms"`
+	P99     float64           `json:"p99_ms"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// NewLatencyHistogram 创建延迟直方图，buckets 为分桶上界，为空时使用 DefaultLatencyBuckets
+func NewLatencyHistogram(buckets ...time.Duration) *LatencyHistogram {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	bounds := append([]time.Duration(nil), buckets...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	return &LatencyHistogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe 记录一次成功调用的耗时
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// ObserveError 记录一次失败的调用，失败调用的耗时不计入分布
+func (h *LatencyHistogram) ObserveError() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errors++
+}
+
+// Count 返回成功调用的样本数
+func (h *LatencyHistogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Quantile 估算分位数（如 0.95），在所属分桶内线性插值，没有样本时返回 false
+func (h *LatencyHistogram) Quantile(q float64) (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.quantile(q)
+}
+
+func (h *LatencyHistogram) quantile(q float64) (time.Duration, bool) {
+	if h.count == 0 {
+		return 0, false
+	}
+	if q < 0 {
+		q = 0
+	}
+	if q > 1 {
+		q = 1
+	}
+
+	rank := q * float64(h.count)
+	var cumulative uint64
+	for i, n := range h.counts {
+		if n == 0 || float64(cumulative+n) < rank {
+			cumulative += n
+			continue
+		}
+
+		// 超过所有上界的样本只知道最大值
+		if i == len(h.bounds) {
+			return h.max, true
+		}
+
+		var lower time.Duration
+		if i > 0 {
+			lower = h.bounds[i-1]
+		}
+		upper := h.bounds[i]
+		if upper > h.max {
+			upper = h.max
+		}
+		if upper < lower {
+			return upper, true
+		}
+		fraction := (rank - float64(cumulative)) / float64(n)
+		return lower + time.Duration(fraction*float64(upper-lower)), true
+	}
+	return h.max, true
+}
+
+// Snapshot 返回直方图的统计快照
+func (h *LatencyHistogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := HistogramSnapshot{
+		Count:   h.count,
+		Errors:  h.errors,
+		Max:     milliseconds(h.max),
+		Buckets: make([]HistogramBucket, 0, len(h.bounds)),
+	}
+	if h.count > 0 {
+		snapshot.Mean = milliseconds(h.sum / time.Duration(h.count))
+		p50, _ := h.quantile(0.5)
+		p95, _ := h.quantile(0.95)
+		p99, _ := h.quantile(0.99)
+		snapshot.P50, snapshot.P95, snapshot.P99 = milliseconds(p50), milliseconds(p95), milliseconds(p99)
+	}
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		snapshot.Buckets = append(snapshot.Buckets, HistogramBucket{LE: milliseconds(bound), Count: cumulative})
+	}
+	return snapshot
+}
+
+// milliseconds 将耗时转换为毫秒
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// LatencyMetrics 按方法、后端和模型分别统计延迟的直方图集合，可并发使用
+//
+// 普通调用统计总耗时，流式调用统计收到第一个数据块的耗时。
+type LatencyMetrics struct {
+	buckets []time.Duration
+
+	mu         sync.RWMutex
+	histograms map[string]*LatencyHistogram
+}
+
+// NewLatencyMetrics 创建延迟统计，buckets 为分桶上界，为空时使用 DefaultLatencyBuckets
+func NewLatencyMetrics(buckets ...time.Duration) *LatencyMetrics {
+	return &LatencyMetrics{
+		buckets:    buckets,
+		histograms: make(map[string]*LatencyHistogram),
+	}
+}
+
+// LatencyKey 返回延迟统计的键，如 "chat openai/gpt-4o"
+func LatencyKey(method, backend, model string) string {
+	return method + " " + backend + "/" + model
+}
+
+// Histogram 返回某个键的直方图，还没有样本时返回 nil
+func (m *LatencyMetrics) Histogram(key string) *LatencyHistogram {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.histograms[key]
+}
+
+// Snapshot 返回所有直方图的统计快照
+func (m *LatencyMetrics) Snapshot() map[string]HistogramSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshots := make(map[string]HistogramSnapshot, len(m.histograms))
+	for key, histogram := range m.histograms {
+		snapshots[key] = histogram.Snapshot()
+	}
+	return snapshots
+}
+
+// Middleware 返回记录调用延迟的中间件
+//
+// 请求未指定模型时按提供者配置中的模型统计；命中缓存的调用不计入，避免拉低延迟分布。
+func (m *LatencyMetrics) Middleware() Middleware {
+	return func(provider Provider) Provider {
+		return WithHooks(Hooks{
+			After: func(ctx context.Context, call *Call) {
+				if call.ChatResponse != nil && call.ChatResponse.Cached {
+					return
+				}
+				if call.CompletionResponse != nil && call.CompletionResponse.Cached {
+					return
+				}
+
+				histogram := m.histogram(LatencyKey(call.Method, call.Backend, latencyModel(call.Model(), provider)))
+				switch {
+				case call.Err != nil:
+					histogram.ObserveError()
+				case call.Method == MethodChatStream:
+					histogram.Observe(call.FirstChunk)
+				default:
+					histogram.Observe(call.Duration)
+				}
+			},
+		})(provider)
+	}
+}
+
+// latencyModel 返回延迟统计使用的模型名：请求指定的模型，未指定时为提供者配置中的模型
+func latencyModel(model string, provider Provider) string {
+	if model != "" {
+		return model
+	}
+	if config := provider.GetConfig(); config != nil {
+		return config.Model
+	}
+	return ""
+}
+
+// histogram 获取或创建某个键的直方图
+func (m *LatencyMetrics) histogram(key string) *LatencyHistogram {
+	if histogram := m.Histogram(key); histogram != nil {
+		return histogram
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if histogram, ok := m.histograms[key]; ok {
+		return histogram
+	}
+	histogram := NewLatencyHistogram(m.buckets...)
+	m.histograms[key] = histogram
+	return histogram
+}
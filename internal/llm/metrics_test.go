@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// stubProvider 返回固定响应的测试提供者
+type stubProvider struct {
+	Provider
+
+	config    *Config
+	modelType ModelType
+	latency   time.Duration
+}
+
+func (p *stubProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	time.Sleep(p.latency)
+	return &ChatResponse{Choices: []ChatChoice{{Message: Message{Role: RoleAssistant, Content: "ok"}}}}, nil
+}
+
+func (p *stubProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	time.Sleep(p.latency)
+	return &CompletionResponse{}, nil
+}
+
+func (p *stubProvider) GetConfig() *Config       { return p.config }
+func (p *stubProvider) GetModelType() ModelType  { return p.modelType }
+func (p *stubProvider) SetConfig(config *Config) { p.config = config }
+
+func TestHedgeDelayUsesMiddlewareKey(t *testing.T) {
+	metrics := NewLatencyMetrics()
+	primary := &stubProvider{config: &Config{Model: "gpt-4o"}, modelType: ModelTypeOpenAI, latency: 20 * time.Millisecond}
+	backup := &stubProvider{config: &Config{Model: "claude-3-haiku"}, modelType: ModelTypeClaude}
+
+	hedged := NewHedgedProvider(&HedgeConfig{Metrics: metrics, MinSamples: 1, Delay: time.Hour},
+		FallbackEntry{Name: "openai", Provider: primary},
+		FallbackEntry{Name: "claude", Provider: backup, Model: "claude-3-haiku"},
+	)
+	provider := Wrap(hedged, metrics.Middleware())
+
+	// 未指定模型时按配置中的模型统计，指定时按请求的模型统计
+	for _, model := range []string{"", "gpt-4o-mini"} {
+		if _, err := provider.Chat(context.Background(), &ChatRequest{Model: model}); err != nil {
+			t.Fatalf("Chat(%q) error: %v", model, err)
+		}
+		if delay := hedged.delay(MethodChat, model); delay == time.Hour {
+			t.Errorf("delay(%q) = fallback delay, want recorded latency; keys: %v", model, metrics.Snapshot())
+		}
+	}
+}
+
+func TestMiddlewareCompleteBackend(t *testing.T) {
+	metrics := NewLatencyMetrics()
+	fallback := NewFallbackProvider(
+		FallbackEntry{Name: "primary", Provider: &stubProvider{config: &Config{Model: "gpt-4o"}, modelType: ModelTypeOpenAI}},
+	)
+	provider := Wrap(fallback, metrics.Middleware())
+
+	if _, err := provider.Complete(context.Background(), &CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if metrics.Histogram(LatencyKey(MethodComplete, "primary", "gpt-4o")) == nil {
+		t.Errorf("no histogram for responding backend; keys: %v", metrics.Snapshot())
+	}
+}
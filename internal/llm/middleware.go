@@ -0,0 +1,307 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 调用的方法名
+const (
+	MethodChat       = "chat"
+	MethodChatStream = "chat_stream"
+	MethodComplete   = "complete"
+)
+
+// Middleware 提供者中间件，返回包装了日志、指标等逻辑的提供者
+type Middleware func(Provider) Provider
+
+// Wrap 用中间件依次包装提供者，第一个中间件在最外层，最先看到请求
+func Wrap(provider Provider, middlewares ...Middleware) Provider {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			provider = middlewares[i](provider)
+		}
+	}
+	return provider
+}
+
+// Call 一次提供者调用，供钩子读取和修改
+type Call struct {
+	// Method 调用的方法：chat、chat_stream 或 complete
+	Method string
+
+	// ModelType 被调用提供者的类型
+	ModelType ModelType
+
+	// ChatRequest 和 CompletionRequest 按方法填写其一，Before 钩子可以替换请求
+	ChatRequest       *ChatRequest
+	CompletionRequest *CompletionRequest
+
+	Start time.Time
+
+	// 以下字段在调用结束后填写，After 钩子可以替换响应和错误
+
+	// ChatResponse 流式调用时由数据块拼接，含工具调用或未正常结束的流为空
+	ChatResponse       *ChatResponse
+	CompletionResponse *CompletionResponse
+	Err                error
+
+	// Backend 实际应答的后端，响应中没有记录时使用 ModelType
+	Backend  string
+	Usage    Usage
+	Duration time.Duration
+
+	// FirstChunk 流式调用收到第一个数据块的耗时
+	FirstChunk time.Duration
+}
+
+// Model 返回请求的模型名，请求未指定时为空
+func (c *Call) Model() string {
+	switch {
+	case c.ChatRequest != nil:
+		return c.ChatRequest.Model
+	case c.CompletionRequest != nil:
+		return c.CompletionRequest.Model
+	default:
+		return ""
+	}
+}
+
+// Hooks 每次调用前后执行的钩子
+type Hooks struct {
+	// Before 在调用前执行，返回错误时不再调用上游，直接返回该错误
+	Before func(ctx context.Context, call *Call) error
+
+	// After 在调用结束后执行（流式调用在流结束后），Before 返回错误时不执行
+	After func(ctx context.Context, call *Call)
+}
+
+// WithHooks 返回在每次调用前后执行钩子的中间件
+func WithHooks(hooks Hooks) Middleware {
+	return func(provider Provider) Provider {
+		return &hookedProvider{Provider: provider, hooks: hooks}
+	}
+}
+
+// hookedProvider 在调用前后执行钩子的提供者
+type hookedProvider struct {
+	Provider
+
+	hooks Hooks
+}
+
+// begin 创建调用记录并执行 Before 钩子
+func (p *hookedProvider) begin(ctx context.Context, call *Call) error {
+	call.ModelType = p.GetModelType()
+	call.Start = time.Now()
+	if p.hooks.Before == nil {
+		return nil
+	}
+	return p.hooks.Before(ctx, call)
+}
+
+// end 填写耗时和后端并执行 After 钩子
+func (p *hookedProvider) end(ctx context.Context, call *Call, backend string) {
+	call.Duration = time.Since(call.Start)
+	call.Backend = backend
+	if call.Backend == "" {
+		call.Backend = string(call.ModelType)
+	}
+	if p.hooks.After != nil {
+		p.hooks.After(ctx, call)
+	}
+}
+
+// Chat 实现聊天接口
+func (p *hookedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	call := &Call{Method: MethodChat, ChatRequest: req}
+	if err := p.begin(ctx, call); err != nil {
+		return nil, err
+	}
+
+	call.ChatResponse, call.Err = p.Provider.Chat(ctx, call.ChatRequest)
+
+	var backend string
+	if call.ChatResponse != nil {
+		backend, call.Usage = call.ChatResponse.Provider, call.ChatResponse.Usage
+	}
+	p.end(ctx, call, backend)
+
+	return call.ChatResponse, call.Err
+}
+
+// ChatStream 实现流式聊天接口，After 钩子在流结束或 ctx 取消后执行
+func (p *hookedProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	call := &Call{Method: MethodChatStream, ChatRequest: req}
+	if err := p.begin(ctx, call); err != nil {
+		return nil, err
+	}
+
+	stream, err := p.Provider.ChatStream(ctx, call.ChatRequest)
+	if err != nil {
+		call.Err = err
+		p.end(ctx, call, "")
+		return nil, call.Err
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+
+		var backend string
+		collector := newStreamCollector()
+		defer func() {
+			if resp, ok := collector.response(); ok && call.Err == nil {
+				call.ChatResponse = resp
+			}
+			p.end(ctx, call, backend)
+		}()
+
+		for chunk := range stream {
+			if call.FirstChunk == 0 {
+				call.FirstChunk = time.Since(call.Start)
+			}
+			if chunk.Provider != "" {
+				backend = chunk.Provider
+			}
+			if chunk.Usage != nil {
+				call.Usage = *chunk.Usage
+			}
+			if chunk.Err != nil {
+				call.Err = chunk.Err
+			}
+			collector.add(chunk)
+
+			if !sendChunk(ctx, chunks, chunk) {
+				call.Err = ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口
+func (p *hookedProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	call := &Call{Method: MethodComplete, CompletionRequest: req}
+	if err := p.begin(ctx, call); err != nil {
+		return nil, err
+	}
+
+	call.CompletionResponse, call.Err = p.Provider.Complete(ctx, call.CompletionRequest)
+
+	var backend string
+	if call.CompletionResponse != nil {
+		backend, call.Usage = call.CompletionResponse.Provider, call.CompletionResponse.Usage
+	}
+	p.end(ctx, call, backend)
+
+	return call.CompletionResponse, call.Err
+}
+
+// LoggingConfig 日志中间件配置
+type LoggingConfig struct {
+	// Logger 日志输出，为空时使用 logrus 的标准日志
+	Logger logrus.FieldLogger
+
+	// Messages 在 debug 级别额外记录请求消息和响应内容，可能包含敏感信息，默认只记录元数据
+	Messages bool
+}
+
+// Logging 返回记录每次调用的中间件
+//
+// 调用成功时以 info 级别记录后端、模型、耗时和用量，失败时以 warn 级别记录错误。
+func Logging(config *LoggingConfig) Middleware {
+	if config == nil {
+		config = &LoggingConfig{}
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+
+	return WithHooks(Hooks{
+		Before: func(ctx context.Context, call *Call) error {
+			if config.Messages {
+				logger.WithFields(logrus.Fields{
+					"method":   call.Method,
+					"provider": call.ModelType,
+					"model":    call.Model(),
+					"request":  callRequest(call),
+				}).Debug("llm request")
+			}
+			return nil
+		},
+		After: func(ctx context.Context, call *Call) {
+			fields := logrus.Fields{
+				"method":            call.Method,
+				"provider":          call.Backend,
+				"model":             call.Model(),
+				"duration_ms":       call.Duration.Milliseconds(),
+				"prompt_tokens":     call.Usage.PromptTokens,
+				"completion_tokens": call.Usage.CompletionTokens,
+			}
+			if call.Method == MethodChatStream {
+				fields["first_chunk_ms"] = call.FirstChunk.Milliseconds()
+			}
+			if call.ChatResponse != nil {
+				fields["cached"] = call.ChatResponse.Cached
+				fields["attempts"] = call.ChatResponse.Attempts
+			}
+
+			if call.Err != nil {
+				logger.WithFields(fields).WithError(call.Err).Warn("llm call failed")
+				return
+			}
+			logger.WithFields(fields).Info("llm call completed")
+
+			if config.Messages {
+				logger.WithFields(logrus.Fields{
+					"method":   call.Method,
+					"provider": call.Backend,
+					"model":    call.Model(),
+					"response": callResponse(call),
+				}).Debug("llm response")
+			}
+		},
+	})
+}
+
+// callRequest 返回调用的请求内容，用于日志
+func callRequest(call *Call) interface{} {
+	if call.ChatRequest != nil {
+		return call.ChatRequest.Messages
+	}
+	if call.CompletionRequest != nil {
+		return call.CompletionRequest.Prompt
+	}
+	return nil
+}
+
+// callResponse 返回调用的响应内容，用于日志
+func callResponse(call *Call) interface{} {
+	switch {
+	case call.ChatResponse != nil:
+		contents := make([]string, 0, len(call.ChatResponse.Choices))
+		for _, choice := range call.ChatResponse.Choices {
+			if len(choice.Message.ToolCalls) > 0 {
+				contents = append(contents, fmt.Sprintf("%s (%d tool calls)", choice.Message.Content, len(choice.Message.ToolCalls)))
+				continue
+			}
+			contents = append(contents, choice.Message.Content)
+		}
+		return contents
+	case call.CompletionResponse != nil:
+		texts := make([]string, 0, len(call.CompletionResponse.Choices))
+		for _, choice := range call.CompletionResponse.Choices {
+			texts = append(texts, choice.Text)
+		}
+		return texts
+	default:
+		return nil
+	}
+}
@@ -2,7 +2,11 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"time"
 
 	openai "github.com/sashabaranov/go-openai"
@@ -12,6 +16,9 @@ import (
 type OpenAIProvider struct {
 	client *openai.Client
 	config *Config
+
+	// clientConfig 根据配置生成客户端配置，Azure 模式下会替换为 azureClientConfig
+	clientConfig func(config *Config) openai.ClientConfig
 }
 
 // NewOpenAIProvider 创建 OpenAI 提供者
@@ -27,15 +34,21 @@ func NewOpenAIProvider(config *Config) *OpenAIProvider {
 		}
 	}
 
+	return &OpenAIProvider{
+		client:       openai.NewClientWithConfig(openAIClientConfig(config)),
+		config:       config,
+		clientConfig: openAIClientConfig,
+	}
+}
+
+// openAIClientConfig 生成 OpenAI 客户端配置
+func openAIClientConfig(config *Config) openai.ClientConfig {
 	clientConfig := openai.DefaultConfig(config.APIKey)
 	if config.BaseURL != "" {
 		clientConfig.BaseURL = config.BaseURL
 	}
-
-	return &OpenAIProvider{
-		client: openai.NewClientWithConfig(clientConfig),
-		config: config,
-	}
+	clientConfig.HTTPClient = newHTTPClient()
+	return clientConfig
 }
 
 // Chat 实现聊天接口
@@ -44,27 +57,18 @@ func (p *OpenAIProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 		return nil, fmt.Errorf("request cannot be nil")
 	}
 
-	// 转换消息格式
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
-	}
-
-	// 构建请求
-	completionReq := openai.ChatCompletionRequest{
-		Model:       req.Model,
-		Messages:    messages,
-		Temperature: float32(req.Temperature),
-		MaxTokens:   req.MaxTokens,
-		TopP:        float32(req.TopP),
-		Stream:      req.Stream,
+	completionReq, err := p.buildChatRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
 	// 调用 API
-	resp, err := p.client.CreateChatCompletion(ctx, completionReq)
+	var resp openai.ChatCompletionResponse
+	attempts, err := withRetry(ctx, p.config, func(ctx context.Context) error {
+		var err error
+		resp, err = p.client.CreateChatCompletion(ctx, completionReq)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("openai chat completion failed: %w", err)
 	}
@@ -75,35 +79,311 @@ func (p *OpenAIProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 		Object:  resp.Object,
 		Created: resp.Created,
 		Model:   resp.Model,
-		Choices: make([]struct {
-			Index   int `json:"index"`
-			Message struct {
-				Role    string `json:"role"`
-				Content string `json:"content"`
-			} `json:"message"`
-			FinishReason string `json:"finish_reason"`
-		}, len(resp.Choices)),
-		Usage: struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		}{
+		Choices: make([]ChatChoice, len(resp.Choices)),
+		Usage: Usage{
 			PromptTokens:     resp.Usage.PromptTokens,
 			CompletionTokens: resp.Usage.CompletionTokens,
 			TotalTokens:      resp.Usage.TotalTokens,
 		},
+		Attempts:          attempts,
+		SystemFingerprint: resp.SystemFingerprint,
 	}
 
 	for i, choice := range resp.Choices {
 		chatResp.Choices[i].Index = choice.Index
-		chatResp.Choices[i].Message.Role = choice.Message.Role
-		chatResp.Choices[i].Message.Content = choice.Message.Content
+		chatResp.Choices[i].Message = fromOpenAIMessage(choice.Message)
 		chatResp.Choices[i].FinishReason = string(choice.FinishReason)
+		chatResp.Choices[i].LogProbs = fromOpenAILogProbs(choice.LogProbs)
 	}
 
 	return chatResp, nil
 }
 
+// ChatStream 实现流式聊天接口
+func (p *OpenAIProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	// go-openai 的流式数据块不包含 logprobs
+	if req.LogProbs || req.TopLogProbs > 0 {
+		return nil, fmt.Errorf("%w: openai provider does not return logprobs when streaming", ErrUnsupportedParameter)
+	}
+
+	completionReq, err := p.buildChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	completionReq.Stream = true
+	completionReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	var stream *openai.ChatCompletionStream
+	_, release, err := withRetryStream(ctx, p.config, func(ctx context.Context) error {
+		var err error
+		stream, err = p.client.CreateChatCompletionStream(ctx, completionReq)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openai chat stream failed: %w", err)
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+		defer release()
+		defer stream.Close()
+
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				sendChunk(ctx, chunks, ChatStreamChunk{Err: fmt.Errorf("openai chat stream failed: %w", classifyError(err))})
+				return
+			}
+
+			for _, choice := range resp.Choices {
+				chunk := ChatStreamChunk{
+					ID:           resp.ID,
+					Model:        resp.Model,
+					Index:        choice.Index,
+					Role:         choice.Delta.Role,
+					Content:      choice.Delta.Content,
+					ToolCalls:    fromOpenAIToolCalls(choice.Delta.ToolCalls),
+					FinishReason: string(choice.FinishReason),
+
+					SystemFingerprint: resp.SystemFingerprint,
+				}
+				if !sendChunk(ctx, chunks, chunk) {
+					return
+				}
+			}
+
+			// 开启 include_usage 后，最后一个数据块的 choices 为空，只携带 usage
+			if resp.Usage != nil {
+				chunk := ChatStreamChunk{
+					ID:    resp.ID,
+					Model: resp.Model,
+					Usage: &Usage{
+						PromptTokens:     resp.Usage.PromptTokens,
+						CompletionTokens: resp.Usage.CompletionTokens,
+						TotalTokens:      resp.Usage.TotalTokens,
+					},
+				}
+				if !sendChunk(ctx, chunks, chunk) {
+					return
+				}
+			}
+		}
+	}()
+
+	return chunks, nil
+}
+
+// buildChatRequest 将通用聊天请求转换为 OpenAI 请求
+func (p *OpenAIProvider) buildChatRequest(req *ChatRequest) (openai.ChatCompletionRequest, error) {
+	// 转换消息格式
+	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
+	for i, msg := range req.Messages {
+		message, err := toOpenAIMessage(msg)
+		if err != nil {
+			return openai.ChatCompletionRequest{}, err
+		}
+		messages[i] = message
+	}
+
+	completionReq := openai.ChatCompletionRequest{
+		Model:            req.Model,
+		Messages:         messages,
+		Temperature:      openAITemperature(req.Temperature),
+		MaxTokens:        req.MaxTokens,
+		TopP:             float32(req.TopP),
+		Stop:             req.Stop,
+		N:                req.N,
+		Seed:             req.Seed,
+		PresencePenalty:  float32(req.PresencePenalty),
+		FrequencyPenalty: float32(req.FrequencyPenalty),
+		LogitBias:        req.LogitBias,
+		LogProbs:         req.LogProbs || req.TopLogProbs > 0,
+		TopLogProbs:      req.TopLogProbs,
+		User:             req.User,
+	}
+
+	// 转换工具定义
+	if len(req.Tools) > 0 {
+		completionReq.Tools = make([]openai.Tool, len(req.Tools))
+		for i, tool := range req.Tools {
+			function := openai.FunctionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			}
+			completionReq.Tools[i] = openai.Tool{
+				Type:     openai.ToolType(tool.Type),
+				Function: &function,
+			}
+		}
+	}
+
+	switch req.ToolChoice {
+	case "":
+	case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+		completionReq.ToolChoice = req.ToolChoice
+	default:
+		// 指定函数名时强制调用该函数
+		completionReq.ToolChoice = openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: req.ToolChoice},
+		}
+	}
+
+	completionReq.ResponseFormat = toOpenAIResponseFormat(req.ResponseFormat)
+
+	return completionReq, nil
+}
+
+// openAITemperature 转换 temperature
+//
+// go-openai 的 Temperature 带 omitempty，0 会被省略而使用服务端默认的 1，因此映射为最小的正数，效果等同于贪心解码。
+func openAITemperature(temperature float64) float32 {
+	if temperature <= 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return float32(temperature)
+}
+
+// toOpenAIResponseFormat 转换响应格式，json_schema 没有 Schema 时退化为 json_object
+func toOpenAIResponseFormat(format *ResponseFormat) *openai.ChatCompletionResponseFormat {
+	if format == nil || format.Type == "" {
+		return nil
+	}
+
+	if format.Type != ResponseFormatJSONSchema {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatType(format.Type)}
+	}
+	if format.Schema == nil {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+
+	name := format.Name
+	if name == "" {
+		name = "response"
+	}
+	// JSONSchema 只包含基本类型，不会编码失败
+	schema, _ := json.Marshal(format.Schema)
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   name,
+			Schema: json.RawMessage(schema),
+			Strict: format.Strict,
+		},
+	}
+}
+
+// toOpenAIMessage 将通用消息转换为 OpenAI 消息，带有片段时转换为 MultiContent
+func toOpenAIMessage(msg Message) (openai.ChatCompletionMessage, error) {
+	message := openai.ChatCompletionMessage{
+		Role:       msg.Role,
+		Content:    msg.Content,
+		Name:       msg.Name,
+		ToolCallID: msg.ToolCallID,
+	}
+
+	if len(msg.Parts) > 0 {
+		// Content 与 MultiContent 不能同时设置
+		message.Content = ""
+		if msg.Content != "" {
+			message.MultiContent = append(message.MultiContent, openai.ChatMessagePart{
+				Type: openai.ChatMessagePartTypeText,
+				Text: msg.Content,
+			})
+		}
+		for _, part := range msg.Parts {
+			if part.Type == ContentPartImage {
+				message.MultiContent = append(message.MultiContent, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: part.dataURL(), Detail: openai.ImageURLDetail(part.Detail)},
+				})
+				continue
+			}
+
+			// go-openai 不支持文件片段，文本类文件作为文本发送
+			text, ok := part.inlineText()
+			if !ok {
+				return message, unsupportedPart("openai", part)
+			}
+			message.MultiContent = append(message.MultiContent, openai.ChatMessagePart{
+				Type: openai.ChatMessagePartTypeText,
+				Text: text,
+			})
+		}
+	}
+
+	for _, call := range msg.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, openai.ToolCall{
+			ID:   call.ID,
+			Type: openai.ToolType(call.Type),
+			Function: openai.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		})
+	}
+
+	return message, nil
+}
+
+// fromOpenAIMessage 将 OpenAI 消息转换为通用消息
+func fromOpenAIMessage(msg openai.ChatCompletionMessage) Message {
+	return Message{
+		Role:       msg.Role,
+		Content:    msg.Content,
+		Name:       msg.Name,
+		ToolCalls:  fromOpenAIToolCalls(msg.ToolCalls),
+		ToolCallID: msg.ToolCallID,
+	}
+}
+
+// fromOpenAILogProbs 转换输出 Token 的对数概率
+func fromOpenAILogProbs(logProbs *openai.LogProbs) []TokenLogProb {
+	if logProbs == nil || len(logProbs.Content) == 0 {
+		return nil
+	}
+
+	result := make([]TokenLogProb, len(logProbs.Content))
+	for i, token := range logProbs.Content {
+		result[i] = TokenLogProb{Token: token.Token, LogProb: token.LogProb, Bytes: token.Bytes}
+		for _, top := range token.TopLogProbs {
+			result[i].TopLogProbs = append(result[i].TopLogProbs, TokenLogProb{Token: top.Token, LogProb: top.LogProb, Bytes: top.Bytes})
+		}
+	}
+	return result
+}
+
+// fromOpenAIToolCalls 转换 OpenAI 工具调用
+func fromOpenAIToolCalls(calls []openai.ToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	result := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		result[i] = ToolCall{
+			Index: call.Index,
+			ID:    call.ID,
+			Type:  string(call.Type),
+			Function: FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		}
+	}
+
+	return result
+}
+
 // Complete 实现补全接口
 func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
 	if req == nil {
@@ -114,14 +394,19 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 	completionReq := openai.CompletionRequest{
 		Model:       req.Model,
 		Prompt:      req.Prompt,
-		Temperature: float32(req.Temperature),
+		Temperature: openAITemperature(req.Temperature),
 		MaxTokens:   req.MaxTokens,
 		TopP:        float32(req.TopP),
 		Stop:        req.Stop,
 	}
 
 	// 调用 API
-	resp, err := p.client.CreateCompletion(ctx, completionReq)
+	var resp openai.CompletionResponse
+	attempts, err := withRetry(ctx, p.config, func(ctx context.Context) error {
+		var err error
+		resp, err = p.client.CreateCompletion(ctx, completionReq)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("openai completion failed: %w", err)
 	}
@@ -138,15 +423,12 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 			Logprobs     interface{} `json:"logprobs"`
 			FinishReason string      `json:"finish_reason"`
 		}, len(resp.Choices)),
-		Usage: struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		}{
+		Usage: Usage{
 			PromptTokens:     resp.Usage.PromptTokens,
 			CompletionTokens: resp.Usage.CompletionTokens,
 			TotalTokens:      resp.Usage.TotalTokens,
 		},
+		Attempts: attempts,
 	}
 
 	for i, choice := range resp.Choices {
@@ -168,11 +450,7 @@ func (p *OpenAIProvider) GetConfig() *Config {
 func (p *OpenAIProvider) SetConfig(config *Config) {
 	p.config = config
 	if config != nil {
-		clientConfig := openai.DefaultConfig(config.APIKey)
-		if config.BaseURL != "" {
-			clientConfig.BaseURL = config.BaseURL
-		}
-		p.client = openai.NewClientWithConfig(clientConfig)
+		p.client = openai.NewClientWithConfig(p.clientConfig(config))
 	}
 }
 
@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAISendsZeroTemperature(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("invalid request body %s: %v", data, err)
+		}
+		bodies = append(bodies, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/completions" {
+			io.WriteString(w, `{"id":"cmpl-1","choices":[{"text":"ok","finish_reason":"stop"}]}`)
+			return
+		}
+		io.WriteString(w, `{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewOpenAIProvider(&Config{APIKey: "test", BaseURL: server.URL, Model: "gpt-4o"})
+	if _, err := p.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}}); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if _, err := p.Complete(context.Background(), &CompletionRequest{Model: "gpt-3.5-turbo-instruct", Prompt: "Hi"}); err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("got %d requests, want 2", len(bodies))
+	}
+	for i, body := range bodies {
+		temperature, ok := body["temperature"].(float64)
+		if !ok || temperature <= 0 || temperature > 1e-6 {
+			t.Errorf("request %d temperature = %v (sent %v), want the smallest positive value", i, body["temperature"], ok)
+		}
+	}
+}
+
+func TestOpenAIBuildChatRequestTools(t *testing.T) {
+	p := NewOpenAIProvider(&Config{APIKey: "test", Model: "gpt-4o"})
+	req := &ChatRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: "What's the weather in Paris?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_1", Type: ToolTypeFunction, Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}},
+			{Role: RoleTool, ToolCallID: "call_1", Content: `{"temp":18}`},
+		},
+		Tools: []Tool{{
+			Type:     ToolTypeFunction,
+			Function: FunctionDefinition{Name: "get_weather", Parameters: map[string]interface{}{"type": "object"}},
+		}},
+		ToolChoice: "get_weather",
+	}
+
+	completionReq, err := p.buildChatRequest(req)
+	if err != nil {
+		t.Fatalf("buildChatRequest error: %v", err)
+	}
+	data, err := json.Marshal(completionReq)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	var got struct {
+		Messages []struct {
+			Role       string `json:"role"`
+			ToolCallID string `json:"tool_call_id"`
+			ToolCalls  []struct {
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"messages"`
+		Tools []struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tools"`
+		ToolChoice struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tool_choice"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+
+	if len(got.Tools) != 1 || got.Tools[0].Type != "function" || got.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("tools = %+v", got.Tools)
+	}
+	if got.ToolChoice.Type != "function" || got.ToolChoice.Function.Name != "get_weather" {
+		t.Errorf("tool_choice = %+v, want forced get_weather", got.ToolChoice)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(got.Messages))
+	}
+	if calls := got.Messages[1].ToolCalls; len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("assistant tool_calls = %+v", calls)
+	}
+	if got.Messages[2].Role != RoleTool || got.Messages[2].ToolCallID != "call_1" {
+		t.Errorf("tool message = %+v", got.Messages[2])
+	}
+}
+
+func TestOpenAIChatToolCallResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"message":{"role":"assistant","content":null,`+
+			`"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},`+
+			`"finish_reason":"tool_calls"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewOpenAIProvider(&Config{APIKey: "test", BaseURL: server.URL, Model: "gpt-4o"})
+	resp, err := p.Chat(context.Background(), &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "What's the weather in Paris?"}},
+		Tools:    []Tool{{Type: ToolTypeFunction, Function: FunctionDefinition{Name: "get_weather"}}},
+	})
+	if err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" {
+		t.Errorf("finish reason = %q, want tool_calls", choice.FinishReason)
+	}
+	calls := choice.Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Type != ToolTypeFunction ||
+		calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+}
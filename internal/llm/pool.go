@@ -0,0 +1,385 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PoolStrategy 号池选择成员的策略
+type PoolStrategy string
+
+const (
+	// PoolRoundRobin 依次轮换
+	PoolRoundRobin PoolStrategy = "round_robin"
+	// PoolLeastInFlight 选择进行中请求最少的成员
+	PoolLeastInFlight PoolStrategy = "least_in_flight"
+	// PoolWeighted 按权重平滑加权轮换
+	PoolWeighted PoolStrategy = "weighted"
+)
+
+const (
+	poolDefaultCooldown         = time.Minute
+	poolDefaultFailureThreshold = 3
+)
+
+// PoolMember 号池中的一个成员，通常是同一后端使用不同 API Key 或地址的提供者
+type PoolMember struct {
+	Name     string
+	Provider Provider
+
+	// Weight 权重，仅 PoolWeighted 策略使用，小于 1 时按 1 处理
+	Weight int
+}
+
+// PoolConfig 号池配置
+type PoolConfig struct {
+	Strategy PoolStrategy
+
+	// Cooldown 成员连续返回限流或鉴权错误后移出轮换的时长，上游要求的 Retry-After 更长时以其为准
+	Cooldown time.Duration
+
+	// FailureThreshold 连续返回限流或鉴权错误多少次后进入冷却
+	FailureThreshold int
+
+	// MaxRetries 所有可用成员都失败后的重试次数，重试之间按指数退避等待
+	MaxRetries int
+}
+
+// poolMember 成员及其运行状态
+type poolMember struct {
+	PoolMember
+
+	inFlight      int
+	failures      int
+	currentWeight int
+	cooldownUntil time.Time
+	cooldownErr   error
+}
+
+// PoolProvider 在多个成员之间分摊请求的提供者
+//
+// 成员返回限流或鉴权错误时，本次调用会立即换一个成员；连续失败达到阈值的成员会冷却一段时间。
+// 所有可用成员都失败后，按 PoolConfig.MaxRetries 退避重试。
+type PoolProvider struct {
+	config *PoolConfig
+
+	mu      sync.Mutex
+	members []*poolMember
+	next    int
+}
+
+// NewPoolProvider 创建号池提供者
+func NewPoolProvider(config *PoolConfig, members ...PoolMember) *PoolProvider {
+	if config == nil {
+		config = &PoolConfig{
+			Strategy:         PoolRoundRobin,
+			Cooldown:         poolDefaultCooldown,
+			FailureThreshold: poolDefaultFailureThreshold,
+		}
+	}
+
+	p := &PoolProvider{config: config}
+	for _, member := range members {
+		if member.Weight < 1 {
+			member.Weight = 1
+		}
+		p.members = append(p.members, &poolMember{PoolMember: member})
+	}
+
+	return p
+}
+
+// newKeyPool 为配置中的每个 API Key 创建一个提供者并组成号池
+//
+// 成员自身不再重试，由号池换成员并按 Config.MaxRetries 统一重试。
+func newKeyPool(config *Config, factory Factory) (Provider, error) {
+	members := make([]PoolMember, 0, len(config.APIKeys))
+	for i, key := range config.APIKeys {
+		memberConfig := *config
+		memberConfig.APIKey = key
+		memberConfig.APIKeys = nil
+		memberConfig.MaxRetries = 0
+
+		provider, err := factory(&memberConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		member := PoolMember{Name: fmt.Sprintf("key-%d", i+1), Provider: provider}
+		if i < len(config.KeyWeights) {
+			member.Weight = config.KeyWeights[i]
+		}
+		members = append(members, member)
+	}
+
+	return NewPoolProvider(&PoolConfig{
+		Strategy:         config.PoolStrategy,
+		Cooldown:         config.PoolCooldown,
+		FailureThreshold: poolDefaultFailureThreshold,
+		MaxRetries:       config.MaxRetries,
+	}, members...), nil
+}
+
+// Chat 实现聊天接口
+func (p *PoolProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	var resp *ChatResponse
+	calls, err := p.do(ctx, func(ctx context.Context, member *poolMember) (bool, error) {
+		var err error
+		resp, err = member.Provider.Chat(ctx, req)
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Attempts = calls
+	return resp, nil
+}
+
+// ChatStream 实现流式聊天接口，成员的进行中计数在流结束时才释放
+func (p *PoolProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	var (
+		stream   <-chan ChatStreamChunk
+		selected *poolMember
+	)
+	_, err := p.do(ctx, func(ctx context.Context, member *poolMember) (bool, error) {
+		var err error
+		stream, err = member.Provider.ChatStream(ctx, req)
+		if err != nil {
+			return false, err
+		}
+		selected = member
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+		defer p.release(selected)
+
+		for chunk := range stream {
+			if !sendChunk(ctx, chunks, chunk) {
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
+
+// Complete 实现补全接口
+func (p *PoolProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	var resp *CompletionResponse
+	calls, err := p.do(ctx, func(ctx context.Context, member *poolMember) (bool, error) {
+		var err error
+		resp, err = member.Provider.Complete(ctx, req)
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Attempts = calls
+	return resp, nil
+}
+
+// do 选择成员执行 call，返回成员调用的总次数
+//
+// call 成功时返回 true 表示由调用方负责之后释放成员的进行中计数（用于流式调用）。
+func (p *PoolProvider) do(ctx context.Context, call func(ctx context.Context, member *poolMember) (bool, error)) (int, error) {
+	if len(p.members) == 0 {
+		return 0, fmt.Errorf("no providers configured for pool")
+	}
+
+	calls := 0
+	_, err := withRetry(ctx, &Config{MaxRetries: p.config.MaxRetries}, func(ctx context.Context) error {
+		tried := make(map[*poolMember]bool)
+		var lastErr error
+		for {
+			member, err := p.acquire(tried)
+			if err != nil {
+				if lastErr != nil {
+					return lastErr
+				}
+				return err
+			}
+			tried[member] = true
+			calls++
+
+			owned, err := call(ctx, member)
+			p.report(member, err)
+			if err == nil {
+				if !owned {
+					p.release(member)
+				}
+				return nil
+			}
+			p.release(member)
+
+			// 限流和鉴权错误只与当前成员有关，立即换下一个成员
+			if ctx.Err() != nil || !(errors.Is(err, ErrRateLimited) || errors.Is(err, ErrAuth)) {
+				return err
+			}
+			lastErr = fmt.Errorf("%s: %w", member.Name, err)
+		}
+	})
+
+	return calls, err
+}
+
+// acquire 按策略选择一个未尝试过且不在冷却中的成员，并增加其进行中计数
+func (p *PoolProvider) acquire(tried map[*poolMember]bool) (*poolMember, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var (
+		candidates []*poolMember
+		soonest    *poolMember
+	)
+	// 从轮换位置开始收集候选，使各策略在并列时也能分摊请求
+	for i := range p.members {
+		member := p.members[(p.next+i)%len(p.members)]
+		if tried[member] {
+			continue
+		}
+		if now.Before(member.cooldownUntil) {
+			if soonest == nil || member.cooldownUntil.Before(soonest.cooldownUntil) {
+				soonest = member
+			}
+			continue
+		}
+		candidates = append(candidates, member)
+	}
+
+	if len(candidates) == 0 {
+		if soonest == nil {
+			return nil, fmt.Errorf("no pool members left to try")
+		}
+		return nil, &ProviderError{
+			Kind:       soonest.cooldownErr,
+			RetryAfter: soonest.cooldownUntil.Sub(now),
+			Err:        fmt.Errorf("all %d pool members are cooling down", len(p.members)),
+		}
+	}
+
+	var selected *poolMember
+	switch p.config.Strategy {
+	case PoolLeastInFlight:
+		for _, member := range candidates {
+			if selected == nil || member.inFlight < selected.inFlight {
+				selected = member
+			}
+		}
+	case PoolWeighted:
+		total := 0
+		for _, member := range candidates {
+			member.currentWeight += member.Weight
+			total += member.Weight
+			if selected == nil || member.currentWeight > selected.currentWeight {
+				selected = member
+			}
+		}
+		selected.currentWeight -= total
+	default:
+		selected = candidates[0]
+	}
+
+	p.next = (p.next + 1) % len(p.members)
+	selected.inFlight++
+	return selected, nil
+}
+
+// release 减少成员的进行中计数
+func (p *PoolProvider) release(member *poolMember) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	member.inFlight--
+}
+
+// report 记录调用结果，连续限流或鉴权失败达到阈值时让成员进入冷却
+func (p *PoolProvider) report(member *poolMember, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var kind error
+	switch {
+	case err == nil:
+		member.failures = 0
+		return
+	case errors.Is(err, ErrRateLimited):
+		kind = ErrRateLimited
+	case errors.Is(err, ErrAuth):
+		kind = ErrAuth
+	default:
+		return
+	}
+
+	member.failures++
+	threshold := p.config.FailureThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	if member.failures < threshold {
+		return
+	}
+
+	cooldown := p.config.Cooldown
+	if cooldown <= 0 {
+		cooldown = poolDefaultCooldown
+	}
+	if retryAfter := RetryAfter(err); retryAfter > cooldown {
+		cooldown = retryAfter
+	}
+	member.failures = 0
+	member.cooldownUntil = time.Now().Add(cooldown)
+	member.cooldownErr = kind
+}
+
+// GetConfig 获取第一个成员的配置
+func (p *PoolProvider) GetConfig() *Config {
+	if len(p.members) == 0 {
+		return nil
+	}
+	return p.members[0].Provider.GetConfig()
+}
+
+// SetConfig 更新所有成员的配置，各成员保留自己的 APIKey 和 BaseURL
+func (p *PoolProvider) SetConfig(config *Config) {
+	for _, member := range p.members {
+		memberConfig := *config
+		if current := member.Provider.GetConfig(); current != nil {
+			memberConfig.APIKey = current.APIKey
+			memberConfig.BaseURL = current.BaseURL
+			memberConfig.MaxRetries = current.MaxRetries
+		}
+		memberConfig.APIKeys = nil
+		member.Provider.SetConfig(&memberConfig)
+	}
+}
+
+// GetModelType 获取第一个成员的模型类型
+func (p *PoolProvider) GetModelType() ModelType {
+	if len(p.members) == 0 {
+		return ""
+	}
+	return p.members[0].Provider.GetModelType()
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// poolMembers 创建统计调用次数的号池成员，failing 中的成员返回限流错误
+func poolMembers(calls map[string]int, failing map[string]bool, weights map[string]int, names ...string) []PoolMember {
+	members := make([]PoolMember, len(names))
+	for i, name := range names {
+		name := name
+		members[i] = PoolMember{Name: name, Weight: weights[name], Provider: &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+			calls[name]++
+			if failing[name] {
+				return nil, &ProviderError{Kind: ErrRateLimited, Err: errors.New("quota exceeded")}
+			}
+			return &ChatResponse{}, nil
+		}}}
+	}
+	return members
+}
+
+func TestPoolStrategies(t *testing.T) {
+	tests := []struct {
+		strategy PoolStrategy
+		weights  map[string]int
+		want     map[string]int
+	}{
+		{PoolRoundRobin, nil, map[string]int{"a": 2, "b": 2}},
+		{PoolLeastInFlight, nil, map[string]int{"a": 2, "b": 2}},
+		{PoolWeighted, map[string]int{"a": 3, "b": 1}, map[string]int{"a": 3, "b": 1}},
+	}
+	for _, tt := range tests {
+		calls := make(map[string]int)
+		p := NewPoolProvider(&PoolConfig{Strategy: tt.strategy}, poolMembers(calls, nil, tt.weights, "a", "b")...)
+		for i := 0; i < 4; i++ {
+			if _, err := p.Chat(context.Background(), &ChatRequest{}); err != nil {
+				t.Fatalf("%s: Chat error: %v", tt.strategy, err)
+			}
+		}
+		if calls["a"] != tt.want["a"] || calls["b"] != tt.want["b"] {
+			t.Errorf("%s: calls = %v, want %v", tt.strategy, calls, tt.want)
+		}
+	}
+}
+
+func TestPoolSkipsRateLimitedMember(t *testing.T) {
+	calls := make(map[string]int)
+	p := NewPoolProvider(&PoolConfig{Strategy: PoolRoundRobin, FailureThreshold: 1, Cooldown: time.Hour},
+		poolMembers(calls, map[string]bool{"a": true}, nil, "a", "b")...)
+
+	// 第一次调用 a 限流后立即换到 b，a 进入冷却，之后的调用都不再经过 a
+	for i := 0; i < 3; i++ {
+		resp, err := p.Chat(context.Background(), &ChatRequest{})
+		if err != nil {
+			t.Fatalf("Chat #%d error: %v", i, err)
+		}
+		if i == 0 && resp.Attempts != 2 {
+			t.Errorf("Attempts = %d, want 2", resp.Attempts)
+		}
+	}
+	if calls["a"] != 1 || calls["b"] != 3 {
+		t.Errorf("calls = %v, want a once, b three times", calls)
+	}
+}
+
+func TestPoolAllMembersCoolingDown(t *testing.T) {
+	calls := make(map[string]int)
+	p := NewPoolProvider(&PoolConfig{FailureThreshold: 1, Cooldown: time.Hour},
+		poolMembers(calls, map[string]bool{"a": true, "b": true}, nil, "a", "b")...)
+
+	if _, err := p.Chat(context.Background(), &ChatRequest{}); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("first Chat error = %v, want ErrRateLimited", err)
+	}
+
+	_, err := p.Chat(context.Background(), &ChatRequest{})
+	if !errors.Is(err, ErrRateLimited) || RetryAfter(err) <= 0 {
+		t.Errorf("Chat error = %v (retry after %v), want ErrRateLimited with the remaining cooldown", err, RetryAfter(err))
+	}
+	if calls["a"] != 1 || calls["b"] != 1 {
+		t.Errorf("calls = %v, want no calls while all members cool down", calls)
+	}
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+)
+
+// Price 模型单价，单位为美元 / 百万 Token
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Cost 按单价计算用量的费用（美元）
+func (p Price) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.Input + float64(usage.CompletionTokens)*p.Output) / 1e6
+}
+
+var (
+	modelPricesMu sync.RWMutex
+
+	// modelPrices 模型名前缀到单价的映射，按最长前缀匹配，可通过 SetModelPrice 覆盖
+	modelPrices = map[string]Price{
+		"gpt-4o":            {Input: 2.5, Output: 10},
+		"gpt-4o-mini":       {Input: 0.15, Output: 0.6},
+		"gpt-4.1":           {Input: 2, Output: 8},
+		"gpt-4.1-mini":      {Input: 0.4, Output: 1.6},
+		"gpt-4.1-nano":      {Input: 0.1, Output: 0.4},
+		"gpt-4-turbo":       {Input: 10, Output: 30},
+		"gpt-4":             {Input: 30, Output: 60},
+		"gpt-4-32k":         {Input: 60, Output: 120},
+		"gpt-3.5-turbo":     {Input: 0.5, Output: 1.5},
+		"o1":                {Input: 15, Output: 60},
+		"o1-mini":           {Input: 3, Output: 12},
+		"o3-mini":           {Input: 1.1, Output: 4.4},
+		"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+		"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+		"claude-3-sonnet":   {Input: 3, Output: 15},
+		"claude-3-5-sonnet": {Input: 3, Output: 15},
+		"claude-3-opus":     {Input: 15, Output: 75},
+	}
+)
+
+// PriceFor 返回模型的单价，未配置单价的模型返回 false
+func PriceFor(model string) (Price, bool) {
+	modelPricesMu.RLock()
+	defer modelPricesMu.RUnlock()
+	price, ok := modelPrices[longestPrefix(modelPrices, model)]
+	return price, ok
+}
+
+// SetModelPrice 设置或覆盖某个模型名前缀的单价
+func SetModelPrice(prefix string, price Price) {
+	modelPricesMu.Lock()
+	defer modelPricesMu.Unlock()
+	modelPrices[strings.ToLower(prefix)] = price
+}
+
+// Cost 计算模型用量的费用（美元），未配置单价的模型返回 false
+func Cost(model string, usage Usage) (float64, bool) {
+	price, ok := PriceFor(model)
+	if !ok {
+		return 0, false
+	}
+	return price.Cost(usage), true
+}
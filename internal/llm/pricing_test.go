@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCostLongestPrefix(t *testing.T) {
+	usage := Usage{PromptTokens: 1000000, CompletionTokens: 500000, TotalTokens: 1500000}
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"gpt-4o-2024-08-06", 2.5 + 5},
+		{"gpt-4o-mini-2024-07-18", 0.15 + 0.3},
+		{"GPT-4-0613", 30 + 30},
+		{"claude-3-5-sonnet-20241022", 3 + 7.5},
+	}
+	for _, tt := range tests {
+		cost, ok := Cost(tt.model, usage)
+		if !ok || math.Abs(cost-tt.want) > 1e-9 {
+			t.Errorf("Cost(%s) = %v, %v, want %v", tt.model, cost, ok, tt.want)
+		}
+	}
+
+	if _, ok := Cost("ernie-4.0-8k", usage); ok {
+		t.Errorf("Cost of unpriced model ok = true, want false")
+	}
+}
+
+func TestSetModelPrice(t *testing.T) {
+	SetModelPrice("Test-Model", Price{Input: 1, Output: 2})
+	defer func() {
+		modelPricesMu.Lock()
+		delete(modelPrices, "test-model")
+		modelPricesMu.Unlock()
+	}()
+
+	cost, ok := Cost("test-model-v2", Usage{PromptTokens: 2000, CompletionTokens: 1000})
+	if !ok || math.Abs(cost-0.004) > 1e-12 {
+		t.Errorf("Cost = %v, %v, want 0.004", cost, ok)
+	}
+}
@@ -0,0 +1,380 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Factory 根据配置创建提供者
+type Factory func(config *Config) (Provider, error)
+
+// Registry 提供者注册表
+//
+// 各提供者以 ModelType 注册构造函数，并声明自己负责的模型名前缀（如 "claude-"）。
+// 模型名也可以显式指定后端，如 "ollama/qwen2"、"azure/gpt-4o"。
+type Registry struct {
+	mu          sync.RWMutex
+	factories   map[ModelType]Factory
+	aliases     map[string]ModelType
+	prefixes    []modelPrefix
+	configs     map[ModelType]*Config
+	instances   map[ModelType]Provider
+	fallbacks   map[ModelType][]string
+	hedging     map[ModelType]*HedgeConfig
+	middlewares []Middleware
+	defaultType ModelType
+}
+
+// modelPrefix 模型名前缀规则
+type modelPrefix struct {
+	prefix    string
+	modelType ModelType
+}
+
+// DefaultRegistry 默认注册表，已注册所有内置提供者
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	DefaultRegistry.Register(ModelTypeOpenAI, func(config *Config) (Provider, error) {
+		return NewOpenAIProvider(config), nil
+	}, "gpt-", "chatgpt-", "o1", "o3", "text-")
+	DefaultRegistry.Register(ModelTypeAzure, func(config *Config) (Provider, error) {
+		return NewAzureOpenAIProvider(config), nil
+	})
+	DefaultRegistry.Register(ModelTypeClaude, func(config *Config) (Provider, error) {
+		return NewClaudeProvider(config), nil
+	}, "claude-")
+	DefaultRegistry.Register(ModelTypeLocal, func(config *Config) (Provider, error) {
+		return NewLocalProvider(config), nil
+	})
+	DefaultRegistry.Register(ModelTypeBaidu, func(config *Config) (Provider, error) {
+		return NewBaiduProvider(config), nil
+	}, "ernie-")
+
+	DefaultRegistry.RegisterAlias("anthropic", ModelTypeClaude)
+	DefaultRegistry.RegisterAlias("ollama", ModelTypeLocal)
+	DefaultRegistry.RegisterAlias("qianfan", ModelTypeBaidu)
+}
+
+// NewRegistry 创建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[ModelType]Factory),
+		aliases:   make(map[string]ModelType),
+		configs:   make(map[ModelType]*Config),
+		instances: make(map[ModelType]Provider),
+		fallbacks: make(map[ModelType][]string),
+		hedging:   make(map[ModelType]*HedgeConfig),
+	}
+}
+
+// Register 注册提供者构造函数，prefixes 为该提供者负责的模型名前缀
+func (r *Registry) Register(modelType ModelType, factory Factory, prefixes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[modelType] = factory
+	r.aliases[string(modelType)] = modelType
+	for _, prefix := range prefixes {
+		r.prefixes = append(r.prefixes, modelPrefix{prefix: strings.ToLower(prefix), modelType: modelType})
+	}
+
+	// 最长前缀优先匹配
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix)
+	})
+}
+
+// RegisterAlias 注册别名，可用于 "别名/模型名" 形式显式指定后端
+func (r *Registry) RegisterAlias(alias string, modelType ModelType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aliases[strings.ToLower(alias)] = modelType
+}
+
+// Configure 设置某类提供者的配置，已创建的实例会在下次获取时重建
+func (r *Registry) Configure(modelType ModelType, config *Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[modelType] = config
+	delete(r.instances, modelType)
+}
+
+// Config 返回某类提供者的配置，未配置时返回 false
+func (r *Registry) Config(modelType ModelType) (*Config, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	config, ok := r.configs[modelType]
+	return config, ok
+}
+
+// SetDefault 设置无法从模型名推断后端时使用的默认提供者
+func (r *Registry) SetDefault(modelType ModelType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultType = modelType
+}
+
+// SetFallbacks 设置某类提供者的降级后端，按顺序在其返回可重试错误时使用
+//
+// 每一项为后端名（如 "claude"，使用该后端配置中的模型）或模型名（如 "azure/gpt-4o"、"ollama/qwen2"）。
+// 设置后 Provider 返回的是包装了降级后端的 *FallbackProvider。
+func (r *Registry) SetFallbacks(modelType ModelType, models ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(models) == 0 {
+		delete(r.fallbacks, modelType)
+		return
+	}
+	r.fallbacks[modelType] = models
+}
+
+// SetHedging 为某类提供者启用对冲请求，config 为 nil 时关闭
+//
+// 启用后 Provider 返回的是 *HedgedProvider：对冲请求按顺序发往 SetFallbacks 设置的降级后端，
+// 没有降级后端时发往同一后端。同一类提供者的所有请求共用 config 中的对冲预算。
+func (r *Registry) SetHedging(modelType ModelType, config *HedgeConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if config == nil {
+		delete(r.hedging, modelType)
+		return
+	}
+	c := *config
+	if c.Budget == nil {
+		c.Budget = NewHedgeBudget(hedgeDefaultBudget)
+	}
+	r.hedging[modelType] = &c
+}
+
+// Use 添加中间件，Provider 和 ProviderFor 返回的提供者（含降级链）会用所有中间件包装，先添加的在外层
+func (r *Registry) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// New 使用给定配置创建一个新的提供者实例
+func (r *Registry) New(modelType ModelType, config *Config) (Provider, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[modelType]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("provider '%s' not registered", modelType)
+	}
+
+	return build(factory, config)
+}
+
+// Provider 获取某类提供者的共享实例，实例按 Configure 设置的配置懒加载创建
+//
+// 通过 SetFallbacks 设置了降级后端时，返回包装了这些后端的 *FallbackProvider；通过 SetHedging 启用了对冲时
+// 返回 *HedgedProvider。通过 Use 添加了中间件时，返回的提供者在最外层用中间件包装。
+func (r *Registry) Provider(modelType ModelType) (Provider, error) {
+	provider, err := r.fallbackChain(modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	middlewares := r.middlewares
+	r.mu.RUnlock()
+
+	return Wrap(provider, middlewares...), nil
+}
+
+// fallbackChain 获取某类提供者的共享实例，设置了降级后端时包装为 *FallbackProvider，启用了对冲时包装为 *HedgedProvider
+func (r *Registry) fallbackChain(modelType ModelType) (Provider, error) {
+	provider, err := r.instance(modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	fallbacks := r.fallbacks[modelType]
+	hedging := r.hedging[modelType]
+	r.mu.RUnlock()
+	if len(fallbacks) == 0 && hedging == nil {
+		return provider, nil
+	}
+
+	entries := []FallbackEntry{{Name: string(modelType), Provider: provider}}
+	for _, fallback := range fallbacks {
+		fallbackType, model, err := r.resolveFallback(fallback)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallback '%s' for provider '%s': %w", fallback, modelType, err)
+		}
+
+		fallbackProvider, err := r.instance(fallbackType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallback '%s' for provider '%s': %w", fallback, modelType, err)
+		}
+
+		entries = append(entries, FallbackEntry{Name: string(fallbackType), Provider: fallbackProvider, Model: model})
+	}
+
+	if hedging != nil {
+		return NewHedgedProvider(hedging, entries...), nil
+	}
+	return NewFallbackProvider(entries...), nil
+}
+
+// instance 获取某类提供者未经包装的共享实例
+func (r *Registry) instance(modelType ModelType) (Provider, error) {
+	r.mu.RLock()
+	provider, ok := r.instances[modelType]
+	r.mu.RUnlock()
+	if ok {
+		return provider, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if provider, ok := r.instances[modelType]; ok {
+		return provider, nil
+	}
+
+	factory, ok := r.factories[modelType]
+	if !ok {
+		return nil, fmt.Errorf("provider '%s' not registered", modelType)
+	}
+
+	config, ok := r.configs[modelType]
+	if !ok {
+		return nil, fmt.Errorf("provider '%s' not configured", modelType)
+	}
+
+	provider, err := build(factory, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider '%s': %w", modelType, err)
+	}
+
+	r.instances[modelType] = provider
+	return provider, nil
+}
+
+// Resolve 根据模型名解析出提供者类型和实际发送给后端的模型名
+//
+// 解析顺序：显式前缀（"ollama/qwen2"）-> 已配置提供者的模型名前缀（"claude-3-haiku"）-> 默认提供者。
+// 模型名为空时返回默认提供者及其配置中的模型。
+func (r *Registry) Resolve(model string) (ModelType, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if model == "" {
+		if r.defaultType == "" {
+			return "", "", fmt.Errorf("model cannot be empty")
+		}
+		if config, ok := r.configs[r.defaultType]; ok && config != nil {
+			return r.defaultType, config.Model, nil
+		}
+		return r.defaultType, "", nil
+	}
+
+	if head, rest, ok := strings.Cut(model, "/"); ok {
+		if modelType, ok := r.aliases[strings.ToLower(head)]; ok {
+			if _, configured := r.configs[modelType]; !configured {
+				return "", "", fmt.Errorf("provider '%s' not configured", modelType)
+			}
+			return modelType, rest, nil
+		}
+	}
+
+	lower := strings.ToLower(model)
+	for _, rule := range r.prefixes {
+		if !strings.HasPrefix(lower, rule.prefix) {
+			continue
+		}
+		// 只路由到已配置的提供者，否则交给默认提供者处理（如 Azure 上的 gpt-4o）
+		if _, configured := r.configs[rule.modelType]; configured {
+			return rule.modelType, model, nil
+		}
+	}
+
+	if r.defaultType == "" {
+		return "", "", fmt.Errorf("no provider found for model '%s'", model)
+	}
+
+	return r.defaultType, model, nil
+}
+
+// build 创建提供者，配置了多个 API Key 时创建号池，配置了限流时加上限流
+//
+// 配置了熔断器时在号池外加上熔断，熔断期间仍可命中缓存；配置了缓存时在最外层依次加上语义缓存和精确缓存，
+// 精确匹配命中时不必计算向量。
+func build(factory Factory, config *Config) (Provider, error) {
+	limited := func(config *Config) (Provider, error) {
+		provider, err := factory(config)
+		if err != nil {
+			return nil, err
+		}
+		return withLimits(provider, config), nil
+	}
+
+	var (
+		provider Provider
+		err      error
+	)
+	if config != nil && len(config.APIKeys) > 1 {
+		provider, err = newKeyPool(config, limited)
+	} else {
+		provider, err = limited(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return withCache(withSemanticCache(withBreaker(provider, config), config), config), nil
+}
+
+// resolveFallback 解析降级后端，只写后端名时使用该后端配置中的模型
+func (r *Registry) resolveFallback(fallback string) (ModelType, string, error) {
+	r.mu.RLock()
+	modelType, ok := r.aliases[strings.ToLower(fallback)]
+	var config *Config
+	if ok {
+		config = r.configs[modelType]
+	}
+	r.mu.RUnlock()
+
+	if !ok {
+		return r.Resolve(fallback)
+	}
+	if config == nil {
+		return "", "", fmt.Errorf("provider '%s' not configured", modelType)
+	}
+	return modelType, config.Model, nil
+}
+
+// ProviderFor 根据模型名获取提供者实例和实际模型名
+func (r *Registry) ProviderFor(model string) (Provider, string, error) {
+	modelType, name, err := r.Resolve(model)
+	if err != nil {
+		return nil, "", err
+	}
+
+	provider, err := r.Provider(modelType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return provider, name, nil
+}
+
+// ModelTypes 列出所有已注册的提供者类型
+func (r *Registry) ModelTypes() []ModelType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]ModelType, 0, len(r.factories))
+	for modelType := range r.factories {
+		types = append(types, modelType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	return types
+}
@@ -0,0 +1,213 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	retryBaseDelay     = 500 * time.Millisecond
+	retryMaxDelay      = 30 * time.Second
+	retryMaxRetryAfter = 60 * time.Second
+)
+
+// RetryError 经过多次尝试后仍然失败的错误，Attempts 为总尝试次数
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+// Error 实现 error 接口
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+// Unwrap 返回最后一次尝试的错误
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// retryHintKey 用于在单次尝试的 context 中传递 retryHint
+type retryHintKey struct{}
+
+// retryAttemptKey 用于在 context 中传递每次重试前执行的函数
+type retryAttemptKey struct{}
+
+// withRetryAttempt 返回在每次重试（不含第一次尝试）前执行 before 的 context，before 返回错误时不再重试
+//
+// LimitedProvider 用它让重试同样经过限流。
+func withRetryAttempt(ctx context.Context, before func(ctx context.Context) error) context.Context {
+	return context.WithValue(ctx, retryAttemptKey{}, before)
+}
+
+// withoutRetryAttempt 清除 withRetryAttempt 设置的函数
+//
+// 只有限流提供者直接包装的那一层重试需要经过限流；单次尝试以及降级、对冲、语义缓存等内部再发起的调用
+// 都应清除它，否则内层的重试会重复计入外层限流。
+func withoutRetryAttempt(ctx context.Context) context.Context {
+	if ctx.Value(retryAttemptKey{}) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, retryAttemptKey{}, nil)
+}
+
+// retryHint 记录上游响应中的 Retry-After，由 retryTransport 填充
+type retryHint struct {
+	retryAfter time.Duration
+}
+
+// retryTransport 记录 429 / 503 响应中的 Retry-After 头
+//
+// go-openai 返回的错误不包含响应头，因此通过 Transport 把它写入当前尝试的 context。
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok {
+			hint.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+	}
+
+	return resp, err
+}
+
+// newHTTPClient 创建各提供者使用的 HTTP 客户端
+//
+// 不设置 Client.Timeout，超时由每次尝试的 context 控制，避免截断流式响应。
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: &retryTransport{}}
+}
+
+// withRetry 执行调用，对限流、5xx 和网络错误按指数退避加随机抖动重试
+//
+// 每次尝试使用由 Config.Timeout 派生的独立超时，最多重试 Config.MaxRetries 次。
+// 返回总尝试次数；错误会经 classifyError 归类，多次尝试后仍失败时再包装为 *RetryError。
+func withRetry(ctx context.Context, config *Config, call func(ctx context.Context) error) (int, error) {
+	return retryLoop(ctx, config, func(ctx context.Context, timeout time.Duration) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return call(ctx)
+	})
+}
+
+// withRetryStream 与 withRetry 相同，但超时只作用于建立流的阶段
+//
+// 成功后返回的 release 必须在流读取结束时调用，以释放该次尝试的 context。
+func withRetryStream(ctx context.Context, config *Config, call func(ctx context.Context) error) (int, context.CancelFunc, error) {
+	var release context.CancelFunc
+	attempts, err := retryLoop(ctx, config, func(ctx context.Context, timeout time.Duration) error {
+		ctx, cancel := context.WithCancel(ctx)
+		if timeout > 0 {
+			timer := time.AfterFunc(timeout, cancel)
+			defer timer.Stop()
+		}
+
+		if err := call(ctx); err != nil {
+			cancel()
+			return err
+		}
+		release = cancel
+		return nil
+	})
+	if err != nil {
+		return attempts, nil, err
+	}
+
+	return attempts, release, nil
+}
+
+// retryLoop 重试主循环
+func retryLoop(ctx context.Context, config *Config, attempt func(ctx context.Context, timeout time.Duration) error) (int, error) {
+	maxRetries := 0
+	var timeout time.Duration
+	if config != nil {
+		maxRetries = config.MaxRetries
+		timeout = config.Timeout
+	}
+
+	before, _ := ctx.Value(retryAttemptKey{}).(func(ctx context.Context) error)
+	attemptCtx := withoutRetryAttempt(ctx)
+
+	for attempts := 1; ; attempts++ {
+		hint := &retryHint{}
+		err := attempt(context.WithValue(attemptCtx, retryHintKey{}, hint), timeout)
+		if err == nil {
+			return attempts, nil
+		}
+		err = classifyError(err)
+
+		// go-openai 的错误不带响应头，补上 retryTransport 记录的 Retry-After
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) && providerErr.RetryAfter == 0 {
+			providerErr.RetryAfter = hint.retryAfter
+		}
+
+		retryable, retryAfter := classifyRetry(ctx, err)
+		if !retryable || attempts > maxRetries {
+			if attempts > 1 {
+				err = &RetryError{Attempts: attempts, Err: err}
+			}
+			return attempts, err
+		}
+
+		timer := time.NewTimer(backoffDelay(attempts, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempts, &RetryError{Attempts: attempts, Err: err}
+		case <-timer.C:
+		}
+
+		if before != nil {
+			if beforeErr := before(ctx); beforeErr != nil {
+				return attempts, &RetryError{Attempts: attempts, Err: err}
+			}
+		}
+	}
+}
+
+// backoffDelay 计算第 attempts 次失败后的等待时间，Retry-After 优先
+func backoffDelay(attempts int, retryAfter time.Duration) time.Duration {
+	delay := retryBaseDelay << (attempts - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	// 在 [delay/2, delay) 范围内随机抖动，避免多个客户端同时重试
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+
+	if retryAfter > delay {
+		delay = retryAfter
+		if delay > retryMaxRetryAfter {
+			delay = retryMaxRetryAfter
+		}
+	}
+
+	return delay
+}
+
+// classifyRetry 判断错误是否可以重试，并返回上游要求的等待时间
+//
+// err 应已经过 classifyError 归类；调用方取消或超时后不再重试。
+func classifyRetry(ctx context.Context, err error) (bool, time.Duration) {
+	if ctx.Err() != nil || !IsRetryable(err) {
+		return false, 0
+	}
+	return true, RetryAfter(err)
+}
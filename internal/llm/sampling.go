@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 采样参数名，与 OpenAI 接口的字段名一致
+const (
+	ParamStop             = "stop"
+	ParamN                = "n"
+	ParamSeed             = "seed"
+	ParamPresencePenalty  = "presence_penalty"
+	ParamFrequencyPenalty = "frequency_penalty"
+	ParamLogitBias        = "logit_bias"
+	ParamLogProbs         = "logprobs"
+	ParamTopLogProbs      = "top_logprobs"
+	ParamUser             = "user"
+)
+
+// ErrUnsupportedParameter 后端不支持请求中设置的采样参数
+var ErrUnsupportedParameter = errors.New("unsupported parameter")
+
+// SamplingParams 返回请求中已设置的采样参数名（n 为 0 或 1 视为未设置）
+func SamplingParams(req *ChatRequest) []string {
+	var params []string
+	if len(req.Stop) > 0 {
+		params = append(params, ParamStop)
+	}
+	if req.N > 1 {
+		params = append(params, ParamN)
+	}
+	if req.Seed != nil {
+		params = append(params, ParamSeed)
+	}
+	if req.PresencePenalty != 0 {
+		params = append(params, ParamPresencePenalty)
+	}
+	if req.FrequencyPenalty != 0 {
+		params = append(params, ParamFrequencyPenalty)
+	}
+	if len(req.LogitBias) > 0 {
+		params = append(params, ParamLogitBias)
+	}
+	if req.LogProbs {
+		params = append(params, ParamLogProbs)
+	}
+	if req.TopLogProbs > 0 {
+		params = append(params, ParamTopLogProbs)
+	}
+	if req.User != "" {
+		params = append(params, ParamUser)
+	}
+	return params
+}
+
+// checkParams 检查请求中是否设置了后端不支持的采样参数，supported 为后端支持的参数名
+func checkParams(provider string, req *ChatRequest, supported ...string) error {
+	var unsupported []string
+	for _, param := range SamplingParams(req) {
+		ok := false
+		for _, name := range supported {
+			if param == name {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			unsupported = append(unsupported, param)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%w: %s provider does not support %s", ErrUnsupportedParameter, provider, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package llm
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONSchema JSON Schema 的子集，覆盖由 Go 类型生成结构化输出格式所需的关键字
+type JSONSchema struct {
+	Type        string                 `json:"type,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Enum        []interface{}          `json:"enum,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"`
+
+	// AdditionalProperties 为 false 时不允许未声明的属性，为 *JSONSchema 时约束未声明属性的值
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaOf 按 encoding/json 的编码规则由 Go 类型生成 JSON Schema
+//
+// 字段名取自 json 标签；没有 omitempty 的字段为必填。字段可以用 description 标签添加说明，
+// 用 enum 标签（逗号分隔）限定取值。不支持递归类型、chan、func 和复数。
+func SchemaOf(t reflect.Type) (*JSONSchema, error) {
+	return schemaOf(t, make(map[reflect.Type]bool))
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) (*JSONSchema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}, nil
+	case t == rawMessageType:
+		return &JSONSchema{}, nil
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// 自定义编码的类型无法推断结构，不做约束
+		return &JSONSchema{}, nil
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &JSONSchema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}, nil
+	case reflect.String:
+		return &JSONSchema{Type: "string"}, nil
+	case reflect.Interface:
+		return &JSONSchema{}, nil
+	case reflect.Slice, reflect.Array:
+		// []byte 编码为 base64 字符串
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string"}, nil
+		}
+		items, err := schemaOf(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaOf(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("recursive type %s is not supported", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		schema := &JSONSchema{
+			Type:                 "object",
+			Properties:           make(map[string]*JSONSchema),
+			AdditionalProperties: false,
+		}
+		if err := addFields(schema, t, visiting); err != nil {
+			return nil, err
+		}
+		sort.Strings(schema.Required)
+		return schema, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// addFields 添加结构体字段，未命名的嵌入结构体按 encoding/json 的规则展开
+func addFields(schema *JSONSchema, t reflect.Type, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addFields(schema, embedded, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := schemaOf(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		// ",string" 选项将数字和布尔值编码为字符串
+		if hasTagOption(options, "string") && property.Type != "" && property.Type != "object" && property.Type != "array" {
+			property = &JSONSchema{Type: "string"}
+		}
+		property.Description = field.Tag.Get("description")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			if property.Enum, err = parseEnum(property.Type, enum); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+
+		schema.Properties[name] = property
+		if !hasTagOption(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return nil
+}
+
+// hasTagOption 判断 json 标签是否包含某个选项
+func hasTagOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEnum 按字段类型解析 enum 标签
+func parseEnum(typ, enum string) ([]interface{}, error) {
+	var values []interface{}
+	for _, value := range strings.Split(enum, ",") {
+		switch typ {
+		case "string":
+			values = append(values, value)
+		case "integer":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer enum value %q", value)
+			}
+			values = append(values, n)
+		case "number":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number enum value %q", value)
+			}
+			values = append(values, n)
+		default:
+			return nil, fmt.Errorf("enum is not supported for %s", typ)
+		}
+	}
+	return values, nil
+}
+
+// strict 判断 Schema 是否满足 OpenAI structured outputs 的限制：
+// 所有对象都不允许额外属性且所有属性必填，不包含无约束的值
+func (s *JSONSchema) strict() bool {
+	switch s.Type {
+	case "":
+		return false
+	case "object":
+		if s.AdditionalProperties != false || len(s.Required) != len(s.Properties) {
+			return false
+		}
+		for _, property := range s.Properties {
+			if !property.strict() {
+				return false
+			}
+		}
+	case "array":
+		return s.Items != nil && s.Items.strict()
+	}
+	return true
+}
+
+// Validate 校验 JSON 解码后的值（数字需为 json.Number）是否符合 Schema，返回所有不符合的位置
+func (s *JSONSchema) Validate(value interface{}) error {
+	var problems []string
+	s.validate(value, "$", &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s *JSONSchema) validate(value interface{}, path string, problems *[]string) {
+	if s.Type == "" {
+		return
+	}
+	if value == nil {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got null", path, s.Type))
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected object, got %s", path, jsonTypeOf(value)))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				property.validate(object[name], path+"."+name, problems)
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					*problems = append(*problems, fmt.Sprintf("%s: unexpected property %q", path, name))
+				}
+			case *JSONSchema:
+				additional.validate(object[name], path+"."+name, problems)
+			}
+		}
+		return
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected array, got %s", path, jsonTypeOf(value)))
+			return
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+		return
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected string, got %s", path, jsonTypeOf(value)))
+			return
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, text); err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s: expected RFC 3339 date-time, got %q", path, text))
+				return
+			}
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected integer, got %s", path, jsonTypeOf(value)))
+			return
+		}
+		if _, err := number.Int64(); err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: expected integer, got %s", path, number))
+			return
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected number, got %s", path, jsonTypeOf(value)))
+			return
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected boolean, got %s", path, jsonTypeOf(value)))
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		for _, allowed := range s.Enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				return
+			}
+		}
+		*problems = append(*problems, fmt.Sprintf("%s: %v is not one of %v", path, value, s.Enum))
+	}
+}
+
+// jsonTypeOf 返回解码后的值对应的 JSON 类型名，用于错误信息
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number, float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testReview struct {
+	Sentiment string   `json:"sentiment" enum:"positive,negative,neutral" description:"整体情绪"`
+	Score     int      `json:"score"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// decodeValue 按 Validate 的要求（数字为 json.Number）解码 JSON
+func decodeValue(t *testing.T, data string) interface{} {
+	t.Helper()
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return value
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := SchemaOf(reflect.TypeOf(testReview{}))
+	if err != nil {
+		t.Fatalf("SchemaOf error: %v", err)
+	}
+
+	if schema.Type != "object" || schema.AdditionalProperties != false {
+		t.Errorf("schema = %+v, want closed object", schema)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"score", "sentiment"}) {
+		t.Errorf("required = %v, want score and sentiment", schema.Required)
+	}
+	sentiment := schema.Properties["sentiment"]
+	if sentiment.Type != "string" || sentiment.Description != "整体情绪" || len(sentiment.Enum) != 3 {
+		t.Errorf("sentiment = %+v", sentiment)
+	}
+	if tags := schema.Properties["tags"]; tags.Type != "array" || tags.Items.Type != "string" {
+		t.Errorf("tags = %+v", tags)
+	}
+	// tags 不是必填，不满足 OpenAI strict 模式的要求
+	if schema.strict() {
+		t.Error("strict() = true, want false with optional properties")
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := SchemaOf(reflect.TypeOf(testReview{}))
+	if err != nil {
+		t.Fatalf("SchemaOf error: %v", err)
+	}
+
+	tests := []struct {
+		name, data string
+		problems   []string
+	}{
+		{"valid", `{"sentiment":"positive","score":5,"tags":["fast"]}`, nil},
+		{"missing required", `{"sentiment":"positive"}`, []string{`$: missing required property "score"`}},
+		{"additional property", `{"sentiment":"positive","score":5,"extra":true}`, []string{`$: unexpected property "extra"`}},
+		{"enum", `{"sentiment":"angry","score":5}`, []string{"$.sentiment: angry is not one of"}},
+		{"type", `{"sentiment":"positive","score":4.5,"tags":[1]}`, []string{"$.score: expected integer", "$.tags[0]: expected string"}},
+		{"null", `null`, []string{"$: expected object, got null"}},
+	}
+	for _, tt := range tests {
+		err := schema.Validate(decodeValue(t, tt.data))
+		if len(tt.problems) == 0 {
+			if err != nil {
+				t.Errorf("%s: Validate error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: Validate = nil, want %v", tt.name, tt.problems)
+			continue
+		}
+		for _, problem := range tt.problems {
+			if !strings.Contains(err.Error(), problem) {
+				t.Errorf("%s: Validate error = %q, want it to contain %q", tt.name, err, problem)
+			}
+		}
+	}
+}
+
+func TestChatJSONRepairs(t *testing.T) {
+	replies := []string{
+		`{"sentiment":"great","score":5}`,
+		"```json\n{\"sentiment\":\"positive\",\"score\":5}\n```",
+	}
+	var requests []*ChatRequest
+	provider := &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+		requests = append(requests, req)
+		reply := replies[len(requests)-1]
+		return &ChatResponse{
+			Choices:  []ChatChoice{{Message: Message{Role: RoleAssistant, Content: reply}}},
+			Usage:    Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+			Attempts: 1,
+		}, nil
+	}}
+
+	review, resp, err := ChatJSON[testReview](context.Background(), provider, &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "评价：很好用"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatJSON error: %v", err)
+	}
+	if review.Sentiment != "positive" || review.Score != 5 {
+		t.Errorf("review = %+v", review)
+	}
+	if resp.Usage.TotalTokens != 30 || resp.Attempts != 2 {
+		t.Errorf("usage = %+v, attempts = %d, want totals of both rounds", resp.Usage, resp.Attempts)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+	if format := requests[0].ResponseFormat; format == nil || format.Type != ResponseFormatJSONSchema || format.Name != "testReview" {
+		t.Errorf("response format = %+v", format)
+	}
+	repair := requests[1].Messages
+	if len(repair) != 3 || repair[1].Role != RoleAssistant || !strings.Contains(repair[2].Content, "great is not one of") {
+		t.Errorf("repair messages = %+v, want the validation error sent back", repair)
+	}
+}
+
+func TestChatJSONGivesUp(t *testing.T) {
+	calls := 0
+	provider := &chatFunc{chat: func(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+		calls++
+		return &ChatResponse{Choices: []ChatChoice{{Message: Message{Content: "not json"}}}}, nil
+	}}
+
+	_, _, err := ChatJSON[testReview](context.Background(), provider, &ChatRequest{})
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("ChatJSON error = %v, want ErrInvalidJSON", err)
+	}
+	if calls != structuredMaxRepairs+1 {
+		t.Errorf("calls = %d, want %d", calls, structuredMaxRepairs+1)
+	}
+}
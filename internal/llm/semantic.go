@@ -0,0 +1,414 @@
+package llm
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	semanticDefaultThreshold  = 0.9
+	semanticDefaultMaxEntries = 1000
+)
+
+// EmbedFunc 计算文本的向量表示
+type EmbedFunc func(ctx context.Context, text string) ([]float32, error)
+
+// SemanticCacheConfig 语义缓存配置
+type SemanticCacheConfig struct {
+	Embed EmbedFunc
+
+	// Threshold 余弦相似度达到该值时视为命中，默认 0.9
+	Threshold float64
+
+	// MaxEntries 最多保存的条目数（所有作用域合计），超出时淘汰最久未使用的条目，默认 1000
+	MaxEntries int
+
+	// TTL 条目的过期时间，为 0 时永不过期
+	TTL time.Duration
+}
+
+// SemanticCacheStats 语义缓存的命中统计
+type SemanticCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Errors    int64 `json:"errors"`
+	Entries   int   `json:"entries"`
+}
+
+// semanticEntry 语义缓存的条目
+type semanticEntry struct {
+	scope     string
+	vector    []float32
+	norm      float64
+	value     interface{}
+	expiresAt time.Time
+}
+
+// SemanticCache 按向量相似度匹配的缓存，条目按作用域（如模板名和模型）隔离，可并发使用
+type SemanticCache struct {
+	config *SemanticCacheConfig
+
+	mu     sync.Mutex
+	scopes map[string]map[*list.Element]struct{}
+	order  *list.List
+	stats  SemanticCacheStats
+}
+
+// NewSemanticCache 创建语义缓存
+func NewSemanticCache(config *SemanticCacheConfig) (*SemanticCache, error) {
+	if config == nil || config.Embed == nil {
+		return nil, fmt.Errorf("semantic cache requires an embed function")
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = semanticDefaultThreshold
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = semanticDefaultMaxEntries
+	}
+
+	return &SemanticCache{
+		config: config,
+		scopes: make(map[string]map[*list.Element]struct{}),
+		order:  list.New(),
+	}, nil
+}
+
+// Get 查找与 text 语义相近的缓存值
+func (c *SemanticCache) Get(ctx context.Context, scope, text string) (interface{}, bool, error) {
+	vector, err := c.embed(ctx, text)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, ok := c.lookup(scope, vector)
+	return value, ok, nil
+}
+
+// Set 写入缓存值
+func (c *SemanticCache) Set(ctx context.Context, scope, text string, value interface{}) error {
+	vector, err := c.embed(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	c.store(scope, vector, value)
+	return nil
+}
+
+// Stats 返回命中统计
+func (c *SemanticCache) Stats() SemanticCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// Step 用语义缓存包装链式调用的步骤，输入为字符串时按 scope 和输入查找缓存，命中时跳过该步骤
+//
+// 返回值可以直接传给 chain.AddStep；计算向量失败时照常执行步骤，不影响调用链。
+func (c *SemanticCache) Step(scope string, step func(ctx context.Context, input interface{}) (interface{}, error)) func(ctx context.Context, input interface{}) (interface{}, error) {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		text, ok := input.(string)
+		if !ok {
+			return step(ctx, input)
+		}
+
+		vector, err := c.embed(ctx, text)
+		if err != nil {
+			return step(ctx, input)
+		}
+		if value, ok := c.lookup(scope, vector); ok {
+			return value, nil
+		}
+
+		output, err := step(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		c.store(scope, vector, output)
+		return output, nil
+	}
+}
+
+// embed 计算向量，失败时计入错误统计
+func (c *SemanticCache) embed(ctx context.Context, text string) ([]float32, error) {
+	vector, err := c.config.Embed(withoutRetryAttempt(ctx), text)
+	if err == nil && len(vector) == 0 {
+		err = fmt.Errorf("empty embedding")
+	}
+	if err != nil {
+		c.mu.Lock()
+		c.stats.Errors++
+		c.mu.Unlock()
+		return nil, fmt.Errorf("failed to embed text: %w", err)
+	}
+	return vector, nil
+}
+
+// lookup 在作用域内查找相似度最高且达到阈值的条目
+func (c *SemanticCache) lookup(scope string, vector []float32) (interface{}, bool) {
+	norm := vectorNorm(vector)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var (
+		best      *list.Element
+		bestScore float64
+	)
+	for element := range c.scopes[scope] {
+		entry := element.Value.(*semanticEntry)
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			c.remove(element)
+			continue
+		}
+
+		score := cosineSimilarity(vector, norm, entry.vector, entry.norm)
+		if score >= c.config.Threshold && (best == nil || score > bestScore) {
+			best, bestScore = element, score
+		}
+	}
+
+	if best == nil {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.order.MoveToFront(best)
+	return best.Value.(*semanticEntry).value, true
+}
+
+// store 写入条目，超出容量时淘汰最久未使用的条目
+func (c *SemanticCache) store(scope string, vector []float32, value interface{}) {
+	entry := &semanticEntry{scope: scope, vector: vector, norm: vectorNorm(vector), value: value}
+	if c.config.TTL > 0 {
+		entry.expiresAt = time.Now().Add(c.config.TTL)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element := c.order.PushFront(entry)
+	if c.scopes[scope] == nil {
+		c.scopes[scope] = make(map[*list.Element]struct{})
+	}
+	c.scopes[scope][element] = struct{}{}
+
+	for c.order.Len() > c.config.MaxEntries {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove 删除条目，调用方需持有锁
+func (c *SemanticCache) remove(element *list.Element) {
+	scope := element.Value.(*semanticEntry).scope
+	c.order.Remove(element)
+	delete(c.scopes[scope], element)
+	if len(c.scopes[scope]) == 0 {
+		delete(c.scopes, scope)
+	}
+}
+
+// vectorNorm 返回向量的 L2 范数
+func vectorNorm(vector []float32) float64 {
+	sum := 0.0
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum)
+}
+
+// cosineSimilarity 返回两个向量的余弦相似度，维度不同或为零向量时返回 0
+func cosineSimilarity(a []float32, normA float64, b []float32, normB float64) float64 {
+	if len(a) != len(b) || normA == 0 || normB == 0 {
+		return 0
+	}
+
+	dot := 0.0
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot / (normA * normB)
+}
+
+type semanticScopeKey struct{}
+
+// semanticScope 通过 context 传递的语义缓存作用域和匹配文本
+type semanticScope struct {
+	scope string
+	query string
+}
+
+// WithSemanticScope 为语义缓存指定作用域（如模板名）和用于匹配的原始问题
+//
+// query 为空时使用请求中最后一条用户消息；用模板渲染出的 Prompt 做匹配时，模板中相同的部分会抬高相似度，
+// 因此建议传入用户的原始问题。
+func WithSemanticScope(ctx context.Context, scope, query string) context.Context {
+	return context.WithValue(ctx, semanticScopeKey{}, semanticScope{scope: scope, query: query})
+}
+
+// SemanticCachedProvider 按语义相似度缓存聊天响应的提供者
+//
+// 作用域为 WithSemanticScope 指定的作用域加上后端和模型名，多个后端共用一个缓存时也不会互相命中。只缓存单轮纯文本对话：包含助手或工具消息、
+// 附带图片或文件、带有工具定义或设置了采样参数的请求直接交给上游。命中时响应的 Cached 为 true、Usage 为 0。
+type SemanticCachedProvider struct {
+	Provider
+
+	cache *SemanticCache
+}
+
+// NewSemanticCachedProvider 创建带语义缓存的提供者
+func NewSemanticCachedProvider(provider Provider, cache *SemanticCache) *SemanticCachedProvider {
+	return &SemanticCachedProvider{Provider: provider, cache: cache}
+}
+
+// withSemanticCache 配置了语义缓存时用 SemanticCachedProvider 包装提供者
+func withSemanticCache(provider Provider, config *Config) Provider {
+	if config == nil || config.SemanticCache == nil {
+		return provider
+	}
+	return NewSemanticCachedProvider(provider, config.SemanticCache)
+}
+
+// Chat 实现聊天接口
+func (p *SemanticCachedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	scope, vector, ok := p.key(ctx, req)
+	if !ok {
+		return p.Provider.Chat(ctx, req)
+	}
+
+	if value, ok := p.cache.lookup(scope, vector); ok {
+		cached := copyResponse(value.(*ChatResponse))
+		cached.Usage, cached.Attempts, cached.Cached = Usage{}, 0, true
+		return cached, nil
+	}
+
+	resp, err := p.Provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	p.cache.store(scope, vector, copyResponse(resp))
+	return resp, nil
+}
+
+// ChatStream 实现流式聊天接口，命中时回放缓存内容，未命中时在流正常结束后写入缓存
+func (p *SemanticCachedProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	scope, vector, ok := p.key(ctx, req)
+	if !ok {
+		return p.Provider.ChatStream(ctx, req)
+	}
+
+	if value, ok := p.cache.lookup(scope, vector); ok {
+		return replayStream(value.(*ChatResponse)), nil
+	}
+
+	stream, err := p.Provider.ChatStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan ChatStreamChunk)
+	go func() {
+		defer close(chunks)
+
+		collector := newStreamCollector()
+		for chunk := range stream {
+			collector.add(chunk)
+			if !sendChunk(ctx, chunks, chunk) {
+				return
+			}
+		}
+
+		if resp, ok := collector.response(); ok {
+			p.cache.store(scope, vector, resp)
+		}
+	}()
+
+	return chunks, nil
+}
+
+// key 返回请求的作用域和匹配文本的向量，请求不可缓存或计算向量失败时返回 false
+func (p *SemanticCachedProvider) key(ctx context.Context, req *ChatRequest) (string, []float32, bool) {
+	if len(req.Tools) > 0 {
+		return "", nil, false
+	}
+	// 设置了 stop、n、seed 等采样参数的请求（如评测）需要按参数生成，不用相近问题的回答代替
+	for _, param := range SamplingParams(req) {
+		if param != ParamUser {
+			return "", nil, false
+		}
+	}
+
+	// 系统消息不同时回答也可能不同，计入作用域
+	var text, system string
+	for _, message := range req.Messages {
+		// 附带图片或文件时只比较文本并不可靠
+		if len(message.Parts) > 0 {
+			return "", nil, false
+		}
+
+		switch message.Role {
+		case RoleAssistant, RoleTool:
+			return "", nil, false
+		case RoleUser:
+			text = message.Content
+		case RoleSystem:
+			system += message.Content + "\x00"
+		}
+	}
+
+	scope, _ := ctx.Value(semanticScopeKey{}).(semanticScope)
+	if scope.query != "" {
+		text = scope.query
+	}
+	if text == "" {
+		return "", nil, false
+	}
+
+	model := req.Model
+	if model == "" {
+		if config := p.GetConfig(); config != nil {
+			model = config.Model
+		}
+	}
+
+	vector, err := p.cache.embed(ctx, text)
+	if err != nil {
+		return "", nil, false
+	}
+
+	// 要求结构化输出时按格式名隔离，避免返回其他格式的回复
+	var format string
+	if req.ResponseFormat != nil {
+		format = req.ResponseFormat.Type + "/" + req.ResponseFormat.Name
+	}
+
+	return scope.scope + "\x00" + string(p.GetModelType()) + "\x00" + model + "\x00" + system + "\x00" + format, vector, true
+}
+
+// copyResponse 复制响应，避免调用方修改缓存中的内容
+func copyResponse(resp *ChatResponse) *ChatResponse {
+	copied := *resp
+	copied.Choices = append([]ChatChoice(nil), resp.Choices...)
+	return &copied
+}
@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// semanticKeywords 测试用向量化的维度，文本包含某个关键词时对应维度为 1
+var semanticKeywords = []string{"weather", "price", "capital"}
+
+func keywordEmbed(ctx context.Context, text string) ([]float32, error) {
+	vector := make([]float32, len(semanticKeywords))
+	for i, keyword := range semanticKeywords {
+		if strings.Contains(strings.ToLower(text), keyword) {
+			vector[i] = 1
+		}
+	}
+	return vector, nil
+}
+
+// countingProvider 统计 Chat 调用次数的测试提供者
+type countingProvider struct {
+	*stubProvider
+
+	calls int
+}
+
+func (p *countingProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	return p.stubProvider.Chat(ctx, req)
+}
+
+func newTestSemanticCache(t *testing.T, maxEntries int) *SemanticCache {
+	t.Helper()
+	cache, err := NewSemanticCache(&SemanticCacheConfig{Embed: keywordEmbed, Threshold: 0.9, MaxEntries: maxEntries})
+	if err != nil {
+		t.Fatalf("NewSemanticCache error: %v", err)
+	}
+	return cache
+}
+
+func userRequest(text string) *ChatRequest {
+	return &ChatRequest{Model: "m", Messages: []Message{{Role: RoleUser, Content: text}}}
+}
+
+func TestSemanticCachedProviderScopesByBackend(t *testing.T) {
+	cache := newTestSemanticCache(t, 10)
+	openai := &countingProvider{stubProvider: &stubProvider{config: &Config{}, modelType: ModelTypeOpenAI}}
+	local := &countingProvider{stubProvider: &stubProvider{config: &Config{}, modelType: ModelTypeLocal}}
+
+	ctx := WithSemanticScope(context.Background(), "qa", "")
+	for _, p := range []*countingProvider{openai, local} {
+		if _, err := NewSemanticCachedProvider(p, cache).Chat(ctx, userRequest("What is the weather?")); err != nil {
+			t.Fatalf("Chat error: %v", err)
+		}
+	}
+
+	if openai.calls != 1 || local.calls != 1 {
+		t.Errorf("calls = %d, %d, want each backend to answer its own request", openai.calls, local.calls)
+	}
+}
+
+func TestSemanticCacheHitAndMiss(t *testing.T) {
+	cache := newTestSemanticCache(t, 10)
+	ctx := context.Background()
+
+	if err := cache.Set(ctx, "qa", "What's the weather like today?", "sunny"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+
+	tests := []struct {
+		scope, text string
+		hit         bool
+	}{
+		{"qa", "How is the weather?", true},
+		{"qa", "What is the price?", false},
+		{"other", "How is the weather?", false},
+	}
+	for _, tt := range tests {
+		value, ok, err := cache.Get(ctx, tt.scope, tt.text)
+		if err != nil {
+			t.Fatalf("Get error: %v", err)
+		}
+		if ok != tt.hit || (ok && value != "sunny") {
+			t.Errorf("Get(%s, %q) = %v, %v, want hit %v", tt.scope, tt.text, value, ok, tt.hit)
+		}
+	}
+
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 1 {
+		t.Errorf("stats = %+v, want 1 hit, 2 misses, 1 entry", stats)
+	}
+}
+
+func TestSemanticCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newTestSemanticCache(t, 2)
+	ctx := context.Background()
+
+	cache.Set(ctx, "qa", "weather", "sunny")
+	cache.Set(ctx, "qa", "price", "cheap")
+	// 访问 weather 后 price 成为最久未使用的条目
+	if _, ok, _ := cache.Get(ctx, "qa", "weather"); !ok {
+		t.Fatal("weather not cached")
+	}
+	cache.Set(ctx, "qa", "capital", "Paris")
+
+	if _, ok, _ := cache.Get(ctx, "qa", "price"); ok {
+		t.Error("price still cached, want it evicted")
+	}
+	for _, text := range []string{"weather", "capital"} {
+		if _, ok, _ := cache.Get(ctx, "qa", text); !ok {
+			t.Errorf("%s evicted, want it kept", text)
+		}
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("stats = %+v, want 1 eviction, 2 entries", stats)
+	}
+}
+
+func TestSemanticCacheExpires(t *testing.T) {
+	cache, err := NewSemanticCache(&SemanticCacheConfig{Embed: keywordEmbed, TTL: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewSemanticCache error: %v", err)
+	}
+	ctx := context.Background()
+
+	cache.Set(ctx, "qa", "weather", "sunny")
+	time.Sleep(20 * time.Millisecond)
+	if _, ok, _ := cache.Get(ctx, "qa", "weather"); ok {
+		t.Error("expired entry returned")
+	}
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Errorf("entries = %d, want expired entry removed", stats.Entries)
+	}
+}
+
+func TestSemanticCacheEmbedErrors(t *testing.T) {
+	cache, err := NewSemanticCache(&SemanticCacheConfig{Embed: func(ctx context.Context, text string) ([]float32, error) {
+		return nil, errors.New("embedding service down")
+	}})
+	if err != nil {
+		t.Fatalf("NewSemanticCache error: %v", err)
+	}
+
+	// 计算向量失败时照常执行步骤
+	step := cache.Step("qa", func(ctx context.Context, input interface{}) (interface{}, error) {
+		return "answer", nil
+	})
+	if output, err := step(context.Background(), "weather"); err != nil || output != "answer" {
+		t.Errorf("step = %v, %v, want the step's output", output, err)
+	}
+	if stats := cache.Stats(); stats.Errors != 1 {
+		t.Errorf("errors = %d, want 1", stats.Errors)
+	}
+}
+
+func TestSemanticCacheStep(t *testing.T) {
+	cache := newTestSemanticCache(t, 10)
+	calls := 0
+	step := cache.Step("summary", func(ctx context.Context, input interface{}) (interface{}, error) {
+		calls++
+		return "summary of " + input.(string), nil
+	})
+
+	for _, input := range []string{"the weather today", "weather report"} {
+		output, err := step(context.Background(), input)
+		if err != nil {
+			t.Fatalf("step error: %v", err)
+		}
+		if output != "summary of the weather today" {
+			t.Errorf("step(%q) = %v, want the cached output", input, output)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("step called %d times, want 1", calls)
+	}
+}
+
+func TestSemanticCachedProviderChat(t *testing.T) {
+	cache := newTestSemanticCache(t, 10)
+	upstream := &countingProvider{stubProvider: &stubProvider{config: &Config{}, modelType: ModelTypeOpenAI}}
+	p := NewSemanticCachedProvider(upstream, cache)
+
+	// 模板渲染后的 Prompt 不同，但原始问题相近
+	ctx := WithSemanticScope(context.Background(), "qa", "Will the weather be nice?")
+	if _, err := p.Chat(ctx, userRequest("Template A: Will the weather be nice?")); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	ctx = WithSemanticScope(context.Background(), "qa", "weather tomorrow?")
+	resp, err := p.Chat(ctx, userRequest("Template A: weather tomorrow?"))
+	if err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if !resp.Cached || resp.Usage != (Usage{}) || upstream.calls != 1 {
+		t.Errorf("cached = %v, usage = %+v, upstream calls = %d, want a cache hit", resp.Cached, resp.Usage, upstream.calls)
+	}
+
+	// 多轮对话不走语义缓存
+	req := userRequest("weather?")
+	req.Messages = append([]Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}}, req.Messages...)
+	if resp, err := p.Chat(ctx, req); err != nil || resp.Cached {
+		t.Errorf("multi-turn Chat cached = %v, err = %v, want upstream call", resp != nil && resp.Cached, err)
+	}
+}
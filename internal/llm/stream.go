@@ -0,0 +1,13 @@
+package llm
+
+import "context"
+
+// sendChunk 向流通道发送数据块，ctx 取消时放弃发送并返回 false
+func sendChunk(ctx context.Context, ch chan<- ChatStreamChunk, chunk ChatStreamChunk) bool {
+	select {
+	case ch <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// structuredMaxRepairs 回复校验失败后最多追加的修复轮数
+const structuredMaxRepairs = 2
+
+// ErrInvalidJSON 模型的回复不是符合 Schema 的 JSON
+var ErrInvalidJSON = errors.New("invalid structured output")
+
+// schemaNamePattern OpenAI 要求 json_schema 的名称只包含字母、数字、下划线和连字符
+var schemaNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// ChatJSON 要求模型按 T 的 JSON Schema 回复，并将回复解析为 T
+//
+// 支持原生结构化输出的后端使用 response_format（json_schema），其余后端在系统提示中说明格式。
+// 回复会去掉 Markdown 代码块后按 Schema 校验，不符合时把校验错误发回给模型修正，最多修复 2 轮。
+// 返回的响应的 Usage 和 Attempts 为所有轮次的合计；T 必须是结构体或 map。
+func ChatJSON[T interface{}](ctx context.Context, provider Provider, req *ChatRequest) (*T, *ChatResponse, error) {
+	if req == nil {
+		return nil, nil, fmt.Errorf("request cannot be nil")
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	schema, err := SchemaOf(t)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build schema for %s: %w", t, err)
+	}
+	if schema.Type != "object" {
+		return nil, nil, fmt.Errorf("structured output requires a struct or map type, got %s", t)
+	}
+
+	structured := *req
+	structured.Messages = append([]Message(nil), req.Messages...)
+	structured.ResponseFormat = &ResponseFormat{
+		Type:   ResponseFormatJSONSchema,
+		Name:   schemaName(t),
+		Schema: schema,
+		Strict: schema.strict(),
+	}
+
+	var (
+		usage    Usage
+		attempts int
+	)
+	for repair := 0; ; repair++ {
+		resp, err := provider.Chat(ctx, &structured)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+		attempts += resp.Attempts
+
+		total := *resp
+		total.Usage, total.Attempts = usage, attempts
+
+		if len(resp.Choices) == 0 {
+			return nil, &total, fmt.Errorf("%w: empty response", ErrInvalidJSON)
+		}
+		content := resp.Choices[0].Message.Content
+
+		var value T
+		err = parseJSON(content, schema, &value)
+		if err == nil {
+			return &value, &total, nil
+		}
+		if repair >= structuredMaxRepairs {
+			return nil, &total, fmt.Errorf("%w after %d attempts: %v", ErrInvalidJSON, repair+1, err)
+		}
+
+		structured.Messages = append(structured.Messages,
+			Message{Role: RoleAssistant, Content: content},
+			Message{Role: RoleUser, Content: fmt.Sprintf("上一次的回复不符合要求：%v\n请修正后重新输出完整的 JSON，不要输出其他内容。", err)},
+		)
+	}
+}
+
+// ParseJSON 从模型回复中提取 JSON（去掉 Markdown 代码块和前后的说明文字），按 v 的类型校验后解码到 v
+func ParseJSON(content string, v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return fmt.Errorf("ParseJSON requires a non-nil pointer")
+	}
+
+	schema, err := SchemaOf(t.Elem())
+	if err != nil {
+		return fmt.Errorf("failed to build schema for %s: %w", t.Elem(), err)
+	}
+	return parseJSON(content, schema, v)
+}
+
+// parseJSON 提取、校验并解码回复中的 JSON
+func parseJSON(content string, schema *JSONSchema, v interface{}) error {
+	data := extractJSON(content)
+	if data == "" {
+		return fmt.Errorf("no JSON found in reply")
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if err := schema.Validate(raw); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return nil
+}
+
+// extractJSON 去掉 Markdown 代码块，返回第一个 { 或 [ 到最后一个 } 或 ] 之间的内容
+func extractJSON(content string) string {
+	content = strings.TrimSpace(content)
+
+	if start := strings.Index(content, "```"); start >= 0 {
+		body := content[start+3:]
+		// 跳过代码块的语言标记，如 ```json
+		if newline := strings.IndexByte(body, '\n'); newline >= 0 {
+			body = body[newline+1:]
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			body = body[:end]
+		}
+		content = strings.TrimSpace(body)
+	}
+
+	start := strings.IndexAny(content, "{[")
+	end := strings.LastIndexAny(content, "}]")
+	if start < 0 || end < start {
+		return ""
+	}
+	return content[start : end+1]
+}
+
+// schemaName 由类型名生成 json_schema 的名称
+func schemaName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := schemaNamePattern.ReplaceAllString(t.Name(), "_")
+	if name == "" {
+		name = "response"
+	}
+	return name
+}
+
+// responseFormatInstruction 不支持原生结构化输出的后端在系统提示中追加的格式说明
+func responseFormatInstruction(format *ResponseFormat) string {
+	if format == nil {
+		return ""
+	}
+
+	switch format.Type {
+	case ResponseFormatJSONObject:
+		return "请只输出一个 JSON 对象，不要输出 Markdown 代码块或其他说明文字。"
+	case ResponseFormatJSONSchema:
+		if format.Schema == nil {
+			return "请只输出一个 JSON 对象，不要输出 Markdown 代码块或其他说明文字。"
+		}
+		var schema bytes.Buffer
+		encoder := json.NewEncoder(&schema)
+		encoder.SetEscapeHTML(false)
+		// JSONSchema 只包含基本类型，不会编码失败
+		_ = encoder.Encode(format.Schema)
+		return "请只输出一个符合以下 JSON Schema 的 JSON 对象，不要输出 Markdown 代码块或其他说明文字：\n" + strings.TrimSpace(schema.String())
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,305 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 内置支持的 BPE 编码
+const (
+	EncodingCL100K = "cl100k_base"
+	EncodingO200K  = "o200k_base"
+)
+
+// Tokenizer 分词器
+type Tokenizer interface {
+	// Count 返回文本的 Token 数
+	Count(text string) int
+
+	// Name 编码名称，如 cl100k_base
+	Name() string
+}
+
+// encodingPatterns 各编码的预分词正则
+//
+// 与 tiktoken 相同，但去掉了 RE2 不支持的 `\s+(?!\S)`，由 splitPieces 单独处理。
+// 其中的 \s 按 Unicode 空白字符处理（RE2 的 \s 只匹配 ASCII 空白），见 unicodeSpaces。
+var encodingPatterns = map[string]string{
+	EncodingCL100K: unicodeSpaces(`'(?i:[sdmt]|ll|ve|re)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`),
+	EncodingO200K: unicodeSpaces(`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+`),
+}
+
+// spaceClass 与 Python str.isspace 一致的空白字符集合
+const spaceClass = `\t\n\v\f\r \x{1C}-\x{1F}\x{85}\p{Z}`
+
+// spacePattern 匹配纯空白字符串
+var spacePattern = regexp.MustCompile(`^[` + spaceClass + `]+$`)
+
+// unicodeSpaces 将正则中的 \s 替换为 spaceClass
+func unicodeSpaces(pattern string) string {
+	pattern = strings.ReplaceAll(pattern, `[^\s`, `[^`+spaceClass)
+	return strings.ReplaceAll(pattern, `\s`, `[`+spaceClass+`]`)
+}
+
+// BPETokenizer 与 tiktoken 兼容的字节级 BPE 分词器
+type BPETokenizer struct {
+	name    string
+	ranks   map[string]int
+	pattern *regexp.Regexp
+}
+
+// NewBPETokenizer 从 tiktoken 格式的词表（每行为 "base64 编码的 Token 排名"）创建分词器
+//
+// name 须为 EncodingCL100K 或 EncodingO200K，以选择对应的预分词规则。
+func NewBPETokenizer(name string, ranks io.Reader) (*BPETokenizer, error) {
+	pattern, ok := encodingPatterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+
+	t := &BPETokenizer{
+		name:    name,
+		ranks:   make(map[string]int),
+		pattern: regexp.MustCompile(pattern),
+	}
+
+	scanner := bufio.NewScanner(ranks)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		token, rank, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid rank line: %q", line)
+		}
+		data, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank token %q: %w", token, err)
+		}
+		value, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank %q: %w", rank, err)
+		}
+		t.ranks[string(data)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ranks: %w", err)
+	}
+
+	return t, nil
+}
+
+// Encode 将文本编码为 Token ID 序列（特殊 Token 按普通文本处理）
+func (t *BPETokenizer) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range t.splitPieces(text) {
+		if rank, ok := t.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		tokens = append(tokens, t.bytePairMerge([]byte(piece))...)
+	}
+	return tokens
+}
+
+// Count 返回文本的 Token 数
+func (t *BPETokenizer) Count(text string) int {
+	return len(t.Encode(text))
+}
+
+// Name 编码名称
+func (t *BPETokenizer) Name() string {
+	return t.name
+}
+
+// splitPieces 按预分词正则切分文本
+//
+// 对纯空白的匹配模拟 `\s+(?!\S)`：后面紧跟非空白字符时，最后一个空白字符留给下一段。
+func (t *BPETokenizer) splitPieces(text string) []string {
+	var pieces []string
+	for pos := 0; pos < len(text); {
+		loc := t.pattern.FindStringIndex(text[pos:])
+		if loc == nil {
+			break
+		}
+		start, end := pos+loc[0], pos+loc[1]
+
+		piece := text[start:end]
+		if end < len(text) && spacePattern.MatchString(piece) && !strings.HasSuffix(piece, "\n") && !strings.HasSuffix(piece, "\r") {
+			if _, size := utf8.DecodeLastRuneInString(piece); size < len(piece) {
+				end -= size
+				piece = text[start:end]
+			}
+		}
+
+		pieces = append(pieces, piece)
+		pos = end
+	}
+	return pieces
+}
+
+// bytePairMerge 对不在词表中的片段反复合并排名最低的相邻字节对
+func (t *BPETokenizer) bytePairMerge(piece []byte) []int {
+	// parts 为各 Token 的起始位置
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+
+	for len(parts) > 2 {
+		minRank, minIndex := math.MaxInt, -1
+		for i := 0; i+2 < len(parts); i++ {
+			if rank, ok := t.ranks[string(piece[parts[i]:parts[i+2]])]; ok && rank < minRank {
+				minRank, minIndex = rank, i
+			}
+		}
+		if minIndex < 0 {
+			break
+		}
+		parts = append(parts[:minIndex+1], parts[minIndex+2:]...)
+	}
+
+	tokens := make([]int, 0, len(parts)-1)
+	for i := 0; i+1 < len(parts); i++ {
+		tokens = append(tokens, t.ranks[string(piece[parts[i]:parts[i+1]])])
+	}
+	return tokens
+}
+
+// CharTokenizer 按字符数估算 Token 数的分词器，用于没有对应词表的模型
+//
+// ASCII 约 4 个字符一个 Token，其他字符（如中文）约一个字一个 Token。
+type CharTokenizer struct{}
+
+// Count 返回文本的估算 Token 数
+func (CharTokenizer) Count(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// Name 编码名称
+func (CharTokenizer) Name() string {
+	return "char"
+}
+
+var (
+	encodingsMu sync.RWMutex
+	encodings   = make(map[string]Tokenizer)
+)
+
+// RegisterEncoding 注册编码对应的分词器，TokenizerForModel 会按模型选用
+func RegisterEncoding(tokenizer Tokenizer) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	encodings[tokenizer.Name()] = tokenizer
+}
+
+// LoadEncodings 从目录中加载 cl100k_base.tiktoken 和 o200k_base.tiktoken 词表，不存在的文件会被跳过
+//
+// 词表可从 https://openaipublic.blob.core.windows.net/encodings/ 下载。
+func LoadEncodings(dir string) error {
+	for _, name := range []string{EncodingCL100K, EncodingO200K} {
+		file, err := os.Open(filepath.Join(dir, name+".tiktoken"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open encoding %s: %w", name, err)
+		}
+
+		tokenizer, err := NewBPETokenizer(name, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to load encoding %s: %w", name, err)
+		}
+		RegisterEncoding(tokenizer)
+	}
+
+	return nil
+}
+
+// modelEncodings 模型名前缀到编码的映射，按最长前缀匹配
+var modelEncodings = map[string]string{
+	"gpt-4o":                 EncodingO200K,
+	"gpt-4.1":                EncodingO200K,
+	"chatgpt-4o":             EncodingO200K,
+	"o1":                     EncodingO200K,
+	"o3":                     EncodingO200K,
+	"gpt-4":                  EncodingCL100K,
+	"gpt-3.5":                EncodingCL100K,
+	"text-embedding-3":       EncodingCL100K,
+	"text-embedding-ada-002": EncodingCL100K,
+}
+
+// TokenizerForModel 返回模型对应的分词器，词表未加载或模型未知时返回 CharTokenizer
+func TokenizerForModel(model string) Tokenizer {
+	name := modelEncodings[longestPrefix(modelEncodings, model)]
+
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	if tokenizer, ok := encodings[name]; ok {
+		return tokenizer
+	}
+	return CharTokenizer{}
+}
+
+// CountMessageTokens 按 OpenAI 的计算方式统计消息的 Token 数（含每条消息的格式开销）
+func CountMessageTokens(tokenizer Tokenizer, messages []Message) int {
+	tokens := 3 // 回复的起始标记
+	for _, message := range messages {
+		tokens += countMessage(tokenizer, message)
+	}
+	return tokens
+}
+
+// countMessage 统计单条消息的 Token 数
+func countMessage(tokenizer Tokenizer, message Message) int {
+	tokens := 3 + tokenizer.Count(message.Role) + tokenizer.Count(message.Content)
+	if message.Name != "" {
+		tokens += 1 + tokenizer.Count(message.Name)
+	}
+	for _, call := range message.ToolCalls {
+		tokens += tokenizer.Count(call.Function.Name) + tokenizer.Count(call.Function.Arguments)
+	}
+	for _, part := range message.Parts {
+		if text, ok := part.inlineText(); ok {
+			tokens += tokenizer.Count(text)
+		} else {
+			tokens += imageTokens
+		}
+	}
+	return tokens
+}
+
+// longestPrefix 返回 table 中与 model 匹配的最长前缀，没有时返回空字符串
+func longestPrefix[V interface{}](table map[string]V, model string) string {
+	model = strings.ToLower(model)
+	best := ""
+	for prefix := range table {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	return best
+}
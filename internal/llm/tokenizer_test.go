@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rankFile 生成 tiktoken 格式的词表
+func rankFile(ranks map[string]int) string {
+	var b strings.Builder
+	for token, rank := range ranks {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	return b.String()
+}
+
+func newTestBPE(t *testing.T, name string, ranks map[string]int) *BPETokenizer {
+	t.Helper()
+	tokenizer, err := NewBPETokenizer(name, strings.NewReader(rankFile(ranks)))
+	if err != nil {
+		t.Fatalf("NewBPETokenizer error: %v", err)
+	}
+	return tokenizer
+}
+
+func TestBPESplitPieces(t *testing.T) {
+	tokenizer := newTestBPE(t, EncodingCL100K, nil)
+
+	// 与 tiktoken cl100k_base 的预分词结果一致
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"I'm here", []string{"I", "'m", " here"}},
+		{"12345", []string{"123", "45"}},
+		{"a  b", []string{"a", " ", " b"}},
+		{"line1\n\nline2", []string{"line", "1", "\n\n", "line", "2"}},
+		{"你好，世界", []string{"你好", "，世界"}},
+		{"trailing  ", []string{"trailing", "  "}},
+	}
+	for _, tt := range tests {
+		if got := tokenizer.splitPieces(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPieces(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBPEEncode(t *testing.T) {
+	// cl100k_base 中 "Hello" 为 9906，" world" 为 1917
+	ranks := map[string]int{"Hello": 9906, " world": 1917}
+	for i := 0; i < 256; i++ {
+		if _, ok := ranks[string([]byte{byte(i)})]; !ok {
+			ranks[string([]byte{byte(i)})] = 100000 + i
+		}
+	}
+	ranks["bc"] = 5
+	ranks["ab"] = 10
+	ranks["abc"] = 20
+	tokenizer := newTestBPE(t, EncodingCL100K, ranks)
+
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"Hello world", []int{9906, 1917}},
+		// 整个片段在词表中时直接使用
+		{"abc", []int{20}},
+		// 只有 "bc" 可以合并，"x"+"bc" 不在词表中
+		{"xbc", []int{100000 + 'x', 5}},
+		// 依次合并 "bc"(5)、末尾的 "ab"(10)、"a"+"bc"(20)
+		{"abcab", []int{20, 10}},
+	}
+	for _, tt := range tests {
+		if got := tokenizer.Encode(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Encode(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+		if got := tokenizer.Count(tt.text); got != len(tt.want) {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, len(tt.want))
+		}
+	}
+}
+
+func TestCharTokenizerCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 3},
+		{"你好世界", 4},
+		{"hi 你好", 3},
+	}
+	for _, tt := range tests {
+		if got := (CharTokenizer{}).Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizerForModel(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, EncodingO200K+".tiktoken"), []byte(rankFile(map[string]int{"a": 0})), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadEncodings(dir); err != nil {
+		t.Fatalf("LoadEncodings error: %v", err)
+	}
+	defer func() {
+		encodingsMu.Lock()
+		delete(encodings, EncodingO200K)
+		encodingsMu.Unlock()
+	}()
+
+	tests := []struct {
+		model, want string
+	}{
+		{"gpt-4o-mini", EncodingO200K},
+		{"GPT-4o", EncodingO200K},
+		// cl100k_base 词表未加载，按字符估算
+		{"gpt-4-turbo", "char"},
+		{"claude-3-haiku", "char"},
+	}
+	for _, tt := range tests {
+		if got := TokenizerForModel(tt.model).Name(); got != tt.want {
+			t.Errorf("TokenizerForModel(%q) = %s, want %s", tt.model, got, tt.want)
+		}
+	}
+}
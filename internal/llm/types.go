@@ -16,10 +16,70 @@ const (
 	ModelTypeLocal     ModelType = "local"
 )
 
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// ToolTypeFunction 函数类型的工具
+const ToolTypeFunction = "function"
+
+// 工具选择策略，ToolChoice 也可以直接填写函数名以强制调用该函数
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// 响应格式类型
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
 // Message 消息结构
+//
+// 助手消息可以携带 ToolCalls；工具执行结果以 RoleTool 角色回传，并通过 ToolCallID 关联调用。
+// 用户消息可以通过 Parts 附带图片和文件，Content 不为空时作为第一个文本片段发送。
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"parts,omitempty"`
+	Name       string        `json:"name,omitempty"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// Tool 工具定义
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition 函数定义，Parameters 为 JSON Schema
+type FunctionDefinition struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters"`
+}
+
+// ToolCall 模型发起的工具调用
+type ToolCall struct {
+	// Index 仅在流式数据块中有效，用于拼接同一调用的增量参数
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall 函数调用，Arguments 为 JSON 字符串
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 }
 
 // ChatRequest 聊天请求
@@ -30,27 +90,101 @@ type ChatRequest struct {
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	TopP        float64   `json:"top_p,omitempty"`
 	Stream      bool      `json:"stream,omitempty"`
+	Tools       []Tool    `json:"tools,omitempty"`
+	ToolChoice  string    `json:"tool_choice,omitempty"`
+
+	// ResponseFormat 要求模型输出 JSON，不支持原生结构化输出的后端会改为在系统提示中说明格式
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+	// 其他采样参数，后端不支持已设置的参数时返回 ErrUnsupportedParameter
+	Stop             []string       `json:"stop,omitempty"`
+	N                int            `json:"n,omitempty"`
+	Seed             *int           `json:"seed,omitempty"`
+	PresencePenalty  float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64        `json:"frequency_penalty,omitempty"`
+	LogitBias        map[string]int `json:"logit_bias,omitempty"`
+	LogProbs         bool           `json:"logprobs,omitempty"`
+	TopLogProbs      int            `json:"top_logprobs,omitempty"`
+
+	// User 终端用户标识，用于上游的滥用监测，不影响生成结果
+	User string `json:"user,omitempty"`
+}
+
+// ResponseFormat 响应格式，Type 为 ResponseFormatJSONSchema 时按 Schema 输出
+type ResponseFormat struct {
+	Type   string      `json:"type"`
+	Name   string      `json:"name,omitempty"`
+	Schema *JSONSchema `json:"schema,omitempty"`
+
+	// Strict 要求后端严格按 Schema 生成（OpenAI structured outputs），Schema 需满足其限制
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Usage Token 使用统计
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 // ChatResponse 聊天响应
 type ChatResponse struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	Model   string `json:"model"`
-	Choices []struct {
-		Index   int `json:"index"`
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-		FinishReason string `json:"finish_reason"`
-	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   Usage        `json:"usage"`
+
+	// Attempts 本次调用的总尝试次数（含重试），大于 1 表示发生过重试
+	Attempts int `json:"attempts,omitempty"`
+
+	// Provider 实际应答的后端，由 FallbackProvider 填写
+	Provider string `json:"provider,omitempty"`
+
+	// Cached 响应来自缓存，此时 Usage 为 0
+	Cached bool `json:"cached,omitempty"`
+
+	// SystemFingerprint 后端的配置指纹，与 Seed 一起用于判断结果能否复现
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+}
+
+// ChatChoice 聊天响应中的单个候选
+type ChatChoice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+
+	// LogProbs 输出 Token 的对数概率，请求设置了 LogProbs 时返回
+	LogProbs []TokenLogProb `json:"logprobs,omitempty"`
+}
+
+// TokenLogProb 输出 Token 的对数概率，TopLogProbs 为该位置概率最高的候选 Token
+type TokenLogProb struct {
+	Token       string         `json:"token"`
+	LogProb     float64        `json:"logprob"`
+	Bytes       []byte         `json:"bytes,omitempty"`
+	TopLogProbs []TokenLogProb `json:"top_logprobs,omitempty"`
+}
+
+// ChatStreamChunk 流式聊天的增量数据块
+//
+// 每个数据块携带一个 choice 的增量内容；流结束前会额外发送一个只包含 Usage 的数据块
+// （前提是后端返回了用量信息）。Err 不为空时表示流异常终止，之后通道会被关闭。
+type ChatStreamChunk struct {
+	ID           string     `json:"id,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	Index        int        `json:"index"`
+	Role         string     `json:"role,omitempty"`
+	Content      string     `json:"content,omitempty"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	FinishReason string     `json:"finish_reason,omitempty"`
+	Usage        *Usage     `json:"usage,omitempty"`
+	Provider     string     `json:"provider,omitempty"`
+	Err          error      `json:"-"`
+
+	// SystemFingerprint 后端的配置指纹
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
 }
 
 // CompletionRequest 补全请求
@@ -75,11 +209,16 @@ type CompletionResponse struct {
 		Logprobs     interface{} `json:"logprobs"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage Usage `json:"usage"`
+
+	// Attempts 本次调用的总尝试次数（含重试），大于 1 表示发生过重试
+	Attempts int `json:"attempts,omitempty"`
+
+	// Provider 实际应答的后端，由 FallbackProvider 填写
+	Provider string `json:"provider,omitempty"`
+
+	// Cached 响应来自缓存，此时 Usage 为 0
+	Cached bool `json:"cached,omitempty"`
 }
 
 // Config LLM 配置
@@ -91,12 +230,49 @@ type Config struct {
 	MaxRetries  int           `json:"max_retries"`
 	Temperature float64       `json:"temperature"`
 	MaxTokens   int           `json:"max_tokens"`
+
+	// Azure OpenAI 配置：BaseURL 为资源终结点，Deployments 为模型名到部署名的映射
+	APIVersion  string            `json:"api_version,omitempty"`
+	Deployments map[string]string `json:"deployments,omitempty"`
+
+	// SecretKey 百度千帆的 Secret Key，与 APIKey 一起换取 access_token
+	SecretKey string `json:"secret_key,omitempty"`
+
+	// AutoPull 本地模型未安装时自动拉取
+	AutoPull bool `json:"auto_pull,omitempty"`
+
+	// Dimensions 向量化模型输出的维度，仅 OpenAI text-embedding-3 及之后的模型支持，为 0 时使用模型默认维度
+	Dimensions int `json:"dimensions,omitempty"`
+
+	// APIKeys 多个 API Key，多于一个时注册表会为每个 Key 创建提供者并组成号池
+	APIKeys      []string      `json:"api_keys,omitempty"`
+	KeyWeights   []int         `json:"key_weights,omitempty"`
+	PoolStrategy PoolStrategy  `json:"pool_strategy,omitempty"`
+	PoolCooldown time.Duration `json:"pool_cooldown,omitempty"`
+
+	// 客户端限流，为 0 时不限制；使用号池时按每个 Key 分别计算
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `json:"tokens_per_minute,omitempty"`
+	MaxInFlight       int `json:"max_in_flight,omitempty"`
+
+	// Cache 响应缓存存储，为空时不缓存；CacheForce 为 true 时温度大于 0 的请求也缓存
+	Cache      CacheStore `json:"-"`
+	CacheForce bool       `json:"cache_force,omitempty"`
+
+	// SemanticCache 语义缓存，为空时不使用；作用域通过 WithSemanticScope 指定
+	SemanticCache *SemanticCache `json:"-"`
+
+	// Breaker 熔断器，为空时不熔断；熔断器记录后端的健康状态，每个后端应使用单独的实例
+	Breaker *CircuitBreaker `json:"-"`
 }
 
 // Provider LLM 提供者接口
 type Provider interface {
 	// Chat 聊天接口
 	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+
+	// ChatStream 流式聊天接口，返回的通道在流结束、出错或 ctx 取消后关闭
+	ChatStream(ctx context.Context, req *ChatRequest) (<-chan ChatStreamChunk, error)
 	
 	// Complete 补全接口
 	Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -11,12 +12,45 @@ import (
 
 // Config 配置结构
 type Config struct {
+	// 默认 LLM 提供者：openai、azure、claude、local、baidu
+	LLMProvider string `json:"llm_provider"`
+	// 默认提供者限流、超时或不可用时依次尝试的降级后端
+	LLMFallbacks []string `json:"llm_fallbacks"`
+
 	// OpenAI 配置
 	OpenAIAPIKey      string `json:"openai_api_key"`
 	OpenAIBaseURL     string `json:"openai_base_url"`
 	OpenAIModel       string `json:"openai_model"`
 	OpenAITemperature float64 `json:"openai_temperature"`
 	OpenAIMaxTokens   int    `json:"openai_max_tokens"`
+	// 多个 API Key 时按号池策略分摊请求，Weights 与 Keys 一一对应
+	OpenAIAPIKeys       []string `json:"openai_api_keys"`
+	OpenAIAPIKeyWeights []int    `json:"openai_api_key_weights"`
+	
+	// 号池配置
+	PoolStrategy string        `json:"pool_strategy"`
+	PoolCooldown time.Duration `json:"pool_cooldown"`
+
+	// Azure OpenAI 配置
+	AzureOpenAIAPIKey      string            `json:"azure_openai_api_key"`
+	AzureOpenAIEndpoint    string            `json:"azure_openai_endpoint"`
+	AzureOpenAIAPIVersion  string            `json:"azure_openai_api_version"`
+	AzureOpenAIDeployments map[string]string `json:"azure_openai_deployments"`
+
+	// Anthropic Claude 配置
+	ClaudeAPIKey  string `json:"claude_api_key"`
+	ClaudeBaseURL string `json:"claude_base_url"`
+	ClaudeModel   string `json:"claude_model"`
+
+	// 本地模型（Ollama）配置
+	LocalBaseURL  string `json:"local_base_url"`
+	LocalModel    string `json:"local_model"`
+	LocalAutoPull bool   `json:"local_auto_pull"`
+
+	// 百度千帆配置
+	BaiduAPIKey    string `json:"baidu_api_key"`
+	BaiduSecretKey string `json:"baidu_secret_key"`
+	BaiduModel     string `json:"baidu_model"`
 	
 	// 服务器配置
 	ServerPort int    `json:"server_port"`
@@ -31,6 +65,55 @@ type Config struct {
 	
 	// 超时配置
 	RequestTimeout time.Duration `json:"request_timeout"`
+	
+	// 重试配置
+	MaxRetries int `json:"max_retries"`
+	
+	// 分词器词表目录，存放 cl100k_base.tiktoken / o200k_base.tiktoken
+	TokenizerDir string `json:"tokenizer_dir"`
+	
+	// 客户端限流配置，为 0 时不限制
+	RequestsPerMinute int `json:"requests_per_minute"`
+	TokensPerMinute   int `json:"tokens_per_minute"`
+	MaxInFlight       int `json:"max_in_flight"`
+	
+	// 响应缓存配置：CacheType 为 memory、disk 或空（不缓存）
+	CacheType  string        `json:"cache_type"`
+	CacheSize  int           `json:"cache_size"`
+	CacheTTL   time.Duration `json:"cache_ttl"`
+	CacheDir   string        `json:"cache_dir"`
+	CacheForce bool          `json:"cache_force"`
+	
+	// 向量化配置：EmbeddingProvider 为 openai、local 或空（不启用），复用对应后端的地址和凭证
+	EmbeddingProvider   string `json:"embedding_provider"`
+	EmbeddingModel      string `json:"embedding_model"`
+	EmbeddingDimensions int    `json:"embedding_dimensions"`
+	
+	// 语义缓存配置，需要启用向量化
+	SemanticCache          bool          `json:"semantic_cache"`
+	SemanticCacheThreshold float64       `json:"semantic_cache_threshold"`
+	SemanticCacheSize      int           `json:"semantic_cache_size"`
+	SemanticCacheTTL       time.Duration `json:"semantic_cache_ttl"`
+	
+	// 模型单价覆盖，模型名前缀到 "输入单价:输出单价"（美元 / 百万 Token）
+	ModelPrices map[string]string `json:"model_prices"`
+	
+	// 熔断配置：BreakerFailureRatio 为 0 时不启用，每个后端分别熔断
+	BreakerFailureRatio     float64       `json:"breaker_failure_ratio"`
+	BreakerMinRequests      int           `json:"breaker_min_requests"`
+	BreakerWindow           time.Duration `json:"breaker_window"`
+	BreakerCooldown         time.Duration `json:"breaker_cooldown"`
+	BreakerHalfOpenRequests int           `json:"breaker_half_open_requests"`
+	
+	// 对冲请求配置：LLMHedge 为 true 时默认后端超过延迟分位数仍未返回时向降级后端（没有时为同一后端）再发一次请求
+	LLMHedge         bool          `json:"llm_hedge"`
+	LLMHedgeQuantile float64       `json:"llm_hedge_quantile"`
+	LLMHedgeDelay    time.Duration `json:"llm_hedge_delay"`
+	LLMHedgeBudget   float64       `json:"llm_hedge_budget"`
+	
+	// 调用日志配置：LLMLogRequests 记录每次调用的后端、耗时和用量，LLMLogMessages 在 debug 级别额外记录消息内容
+	LLMLogRequests bool `json:"llm_log_requests"`
+	LLMLogMessages bool `json:"llm_log_messages"`
 }
 
 // LoadConfig 加载配置
@@ -43,12 +126,45 @@ func LoadConfig() (*Config, error) {
 	
 	config := &Config{}
 	
+	config.LLMProvider = getEnv("LLM_PROVIDER", "openai")
+	config.LLMFallbacks = getEnvList("LLM_FALLBACKS")
+	
 	// 加载 OpenAI 配置
 	config.OpenAIAPIKey = getEnv("OPENAI_API_KEY", "")
 	config.OpenAIBaseURL = getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1")
 	config.OpenAIModel = getEnv("OPENAI_MODEL", "gpt-3.5-turbo")
 	config.OpenAITemperature = getEnvFloat("OPENAI_TEMPERATURE", 0.7)
 	config.OpenAIMaxTokens = getEnvInt("OPENAI_MAX_TOKENS", 1000)
+	config.OpenAIAPIKeys = getEnvList("OPENAI_API_KEYS")
+	config.OpenAIAPIKeyWeights = getEnvIntList("OPENAI_API_KEY_WEIGHTS")
+	if config.OpenAIAPIKey == "" && len(config.OpenAIAPIKeys) > 0 {
+		config.OpenAIAPIKey = config.OpenAIAPIKeys[0]
+	}
+	
+	// 加载号池配置
+	config.PoolStrategy = getEnv("LLM_POOL_STRATEGY", "round_robin")
+	config.PoolCooldown = time.Duration(getEnvInt("LLM_POOL_COOLDOWN_SECONDS", 60)) * time.Second
+	
+	// 加载 Azure OpenAI 配置
+	config.AzureOpenAIAPIKey = getEnv("AZURE_OPENAI_API_KEY", "")
+	config.AzureOpenAIEndpoint = getEnv("AZURE_OPENAI_ENDPOINT", "")
+	config.AzureOpenAIAPIVersion = getEnv("AZURE_OPENAI_API_VERSION", "2024-02-01")
+	config.AzureOpenAIDeployments = getEnvMap("AZURE_OPENAI_DEPLOYMENTS")
+	
+	// 加载 Claude 配置
+	config.ClaudeAPIKey = getEnv("ANTHROPIC_API_KEY", "")
+	config.ClaudeBaseURL = getEnv("ANTHROPIC_BASE_URL", "https://api.anthropic.com/v1")
+	config.ClaudeModel = getEnv("CLAUDE_MODEL", "claude-3-haiku-20240307")
+	
+	// 加载本地模型配置
+	config.LocalBaseURL = getEnv("OLLAMA_BASE_URL", "http://localhost:11434")
+	config.LocalModel = getEnv("OLLAMA_MODEL", "qwen2")
+	config.LocalAutoPull = getEnvBool("OLLAMA_AUTO_PULL", false)
+	
+	// 加载百度千帆配置
+	config.BaiduAPIKey = getEnv("BAIDU_API_KEY", "")
+	config.BaiduSecretKey = getEnv("BAIDU_SECRET_KEY", "")
+	config.BaiduModel = getEnv("BAIDU_MODEL", "ernie-3.5-8k")
 	
 	// 加载服务器配置
 	config.ServerPort = getEnvInt("SERVER_PORT", 8080)
@@ -65,6 +181,55 @@ func LoadConfig() (*Config, error) {
 	timeout := getEnvInt("REQUEST_TIMEOUT_SECONDS", 30)
 	config.RequestTimeout = time.Duration(timeout) * time.Second
 	
+	// 加载重试配置
+	config.MaxRetries = getEnvInt("LLM_MAX_RETRIES", 3)
+	
+	// 加载分词器配置
+	config.TokenizerDir = getEnv("TIKTOKEN_DIR", "")
+	
+	// 加载客户端限流配置
+	config.RequestsPerMinute = getEnvInt("LLM_REQUESTS_PER_MINUTE", 0)
+	config.TokensPerMinute = getEnvInt("LLM_TOKENS_PER_MINUTE", 0)
+	config.MaxInFlight = getEnvInt("LLM_MAX_IN_FLIGHT", 0)
+	
+	// 加载响应缓存配置
+	config.CacheType = getEnv("LLM_CACHE", "")
+	config.CacheSize = getEnvInt("LLM_CACHE_SIZE", 1000)
+	config.CacheTTL = time.Duration(getEnvInt("LLM_CACHE_TTL_SECONDS", 3600)) * time.Second
+	config.CacheDir = getEnv("LLM_CACHE_DIR", ".cache/llm")
+	config.CacheForce = getEnvBool("LLM_CACHE_FORCE", false)
+	
+	// 加载向量化配置
+	config.EmbeddingProvider = getEnv("EMBEDDING_PROVIDER", "")
+	config.EmbeddingModel = getEnv("EMBEDDING_MODEL", "")
+	config.EmbeddingDimensions = getEnvInt("EMBEDDING_DIMENSIONS", 0)
+	
+	// 加载语义缓存配置
+	config.SemanticCache = getEnvBool("LLM_SEMANTIC_CACHE", false)
+	config.SemanticCacheThreshold = getEnvFloat("LLM_SEMANTIC_CACHE_THRESHOLD", 0.9)
+	config.SemanticCacheSize = getEnvInt("LLM_SEMANTIC_CACHE_SIZE", 1000)
+	config.SemanticCacheTTL = time.Duration(getEnvInt("LLM_SEMANTIC_CACHE_TTL_SECONDS", 3600)) * time.Second
+	
+	// 加载计费配置
+	config.ModelPrices = getEnvMap("LLM_PRICES")
+	
+	// 加载熔断配置
+	config.BreakerFailureRatio = getEnvFloat("LLM_BREAKER_FAILURE_RATIO", 0)
+	config.BreakerMinRequests = getEnvInt("LLM_BREAKER_MIN_REQUESTS", 10)
+	config.BreakerWindow = time.Duration(getEnvInt("LLM_BREAKER_WINDOW_SECONDS", 60)) * time.Second
+	config.BreakerCooldown = time.Duration(getEnvInt("LLM_BREAKER_COOLDOWN_SECONDS", 30)) * time.Second
+	config.BreakerHalfOpenRequests = getEnvInt("LLM_BREAKER_HALF_OPEN_REQUESTS", 1)
+	
+	// 加载对冲请求配置
+	config.LLMHedge = getEnvBool("LLM_HEDGE", false)
+	config.LLMHedgeQuantile = getEnvFloat("LLM_HEDGE_QUANTILE", 0.95)
+	config.LLMHedgeDelay = time.Duration(getEnvInt("LLM_HEDGE_DELAY_MS", 2000)) * time.Millisecond
+	config.LLMHedgeBudget = getEnvFloat("LLM_HEDGE_BUDGET", 0.1)
+	
+	// 加载调用日志配置
+	config.LLMLogRequests = getEnvBool("LLM_LOG_REQUESTS", false)
+	config.LLMLogMessages = getEnvBool("LLM_LOG_MESSAGES", false)
+	
 	return config, nil
 }
 
@@ -96,10 +261,121 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getEnvBool 获取布尔环境变量
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList 获取逗号分隔的列表形式的环境变量
+func getEnvList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getEnvIntList 获取逗号分隔的整数列表形式的环境变量，无法解析的项按 0 处理
+func getEnvIntList(key string) []int {
+	var result []int
+	for _, item := range getEnvList(key) {
+		value, _ := strconv.Atoi(item)
+		result = append(result, value)
+	}
+	return result
+}
+
+// getEnvMap 获取键值对形式的环境变量，格式为 "k1=v1,k2=v2"
+func getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if k, v = strings.TrimSpace(k), strings.TrimSpace(v); k != "" && v != "" {
+			result[k] = v
+		}
+	}
+	return result
+}
+
 // ValidateConfig 验证配置
 func ValidateConfig(config *Config) error {
-	if config.OpenAIAPIKey == "" {
-		return fmt.Errorf("OPENAI_API_KEY is required")
+	switch config.LLMProvider {
+	case "openai":
+		if config.OpenAIAPIKey == "" {
+			return fmt.Errorf("OPENAI_API_KEY is required")
+		}
+	case "azure":
+		if config.AzureOpenAIAPIKey == "" {
+			return fmt.Errorf("AZURE_OPENAI_API_KEY is required")
+		}
+		if config.AzureOpenAIEndpoint == "" {
+			return fmt.Errorf("AZURE_OPENAI_ENDPOINT is required")
+		}
+	case "claude":
+		if config.ClaudeAPIKey == "" {
+			return fmt.Errorf("ANTHROPIC_API_KEY is required")
+		}
+	case "local":
+		// 本地模型无需 API Key，适用于离线环境
+		if config.LocalBaseURL == "" {
+			return fmt.Errorf("OLLAMA_BASE_URL is required")
+		}
+	case "baidu":
+		if config.BaiduAPIKey == "" || config.BaiduSecretKey == "" {
+			return fmt.Errorf("BAIDU_API_KEY and BAIDU_SECRET_KEY are required")
+		}
+	default:
+		return fmt.Errorf("unsupported LLM provider: %s", config.LLMProvider)
+	}
+	
+	switch config.PoolStrategy {
+	case "round_robin", "least_in_flight", "weighted":
+	default:
+		return fmt.Errorf("unsupported pool strategy: %s", config.PoolStrategy)
+	}
+	
+	switch config.CacheType {
+	case "", "memory", "disk":
+	default:
+		return fmt.Errorf("unsupported cache type: %s", config.CacheType)
+	}
+	
+	switch config.EmbeddingProvider {
+	case "", "openai", "local":
+	default:
+		return fmt.Errorf("unsupported embedding provider: %s", config.EmbeddingProvider)
+	}
+	
+	if config.SemanticCache {
+		if config.EmbeddingProvider == "" {
+			return fmt.Errorf("LLM_SEMANTIC_CACHE requires EMBEDDING_PROVIDER")
+		}
+		if config.SemanticCacheThreshold <= 0 || config.SemanticCacheThreshold > 1 {
+			return fmt.Errorf("invalid semantic cache threshold: %f", config.SemanticCacheThreshold)
+		}
+	}
+	
+	if config.BreakerFailureRatio < 0 || config.BreakerFailureRatio > 1 {
+		return fmt.Errorf("invalid breaker failure ratio: %f", config.BreakerFailureRatio)
+	}
+	
+	if config.LLMHedge {
+		if config.LLMHedgeQuantile <= 0 || config.LLMHedgeQuantile >= 1 {
+			return fmt.Errorf("invalid hedge quantile: %f", config.LLMHedgeQuantile)
+		}
+		if config.LLMHedgeBudget <= 0 || config.LLMHedgeBudget > 1 {
+			return fmt.Errorf("invalid hedge budget: %f", config.LLMHedgeBudget)
+		}
 	}
 	
 	if config.ServerPort <= 0 || config.ServerPort > 65535 {
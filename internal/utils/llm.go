@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go-llm-tools/internal/llm"
+)
+
+// LoadTokenizers 加载配置的分词器词表，未配置目录时各模型使用按字符估算的分词器
+func LoadTokenizers(config *Config) error {
+	if config.TokenizerDir == "" {
+		return nil
+	}
+	return llm.LoadEncodings(config.TokenizerDir)
+}
+
+// ConfigurePricing 用配置中的单价覆盖内置价格表
+func ConfigurePricing(config *Config) error {
+	for model, value := range config.ModelPrices {
+		input, output, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("invalid price for %s: %q, expected input:output", model, value)
+		}
+
+		var (
+			price llm.Price
+			err   error
+		)
+		if price.Input, err = strconv.ParseFloat(strings.TrimSpace(input), 64); err != nil {
+			return fmt.Errorf("invalid input price for %s: %w", model, err)
+		}
+		if price.Output, err = strconv.ParseFloat(strings.TrimSpace(output), 64); err != nil {
+			return fmt.Errorf("invalid output price for %s: %w", model, err)
+		}
+		llm.SetModelPrice(model, price)
+	}
+	return nil
+}
+
+// ProviderMiddlewares 根据配置创建提供者中间件，启用调用日志时记录到 logger，metrics 不为空时统计调用延迟
+func ProviderMiddlewares(config *Config, logger logrus.FieldLogger, metrics *llm.LatencyMetrics) []llm.Middleware {
+	var middlewares []llm.Middleware
+	if config.LLMLogRequests {
+		middlewares = append(middlewares, llm.Logging(&llm.LoggingConfig{
+			Logger:   logger,
+			Messages: config.LLMLogMessages,
+		}))
+	}
+	if metrics != nil {
+		middlewares = append(middlewares, metrics.Middleware())
+	}
+	return middlewares
+}
+
+// ConfigureHedging 按配置为默认提供者启用对冲请求，对冲延迟取 metrics 中的延迟分位数，未启用时返回 nil
+//
+// 返回的预算记录对冲请求的统计。
+func ConfigureHedging(registry *llm.Registry, config *Config, metrics *llm.LatencyMetrics) *llm.HedgeBudget {
+	if !config.LLMHedge {
+		return nil
+	}
+
+	budget := llm.NewHedgeBudget(config.LLMHedgeBudget)
+	registry.SetHedging(llm.ModelType(config.LLMProvider), &llm.HedgeConfig{
+		Metrics:  metrics,
+		Quantile: config.LLMHedgeQuantile,
+		Delay:    config.LLMHedgeDelay,
+		Budget:   budget,
+	})
+	return budget
+}
+
+// newCircuitBreaker 根据配置创建熔断器，未启用时返回 nil
+func newCircuitBreaker(config *Config) *llm.CircuitBreaker {
+	if config.BreakerFailureRatio <= 0 {
+		return nil
+	}
+	return llm.NewCircuitBreaker(&llm.BreakerConfig{
+		FailureRatio:     config.BreakerFailureRatio,
+		MinRequests:      config.BreakerMinRequests,
+		Window:           config.BreakerWindow,
+		Cooldown:         config.BreakerCooldown,
+		HalfOpenRequests: config.BreakerHalfOpenRequests,
+	})
+}
+
+// newCacheStore 根据配置创建响应缓存存储，未启用缓存时返回 nil
+func newCacheStore(config *Config) llm.CacheStore {
+	switch config.CacheType {
+	case "memory":
+		return llm.NewMemoryCache(config.CacheSize, config.CacheTTL)
+	case "disk":
+		return llm.NewDiskCache(config.CacheDir, config.CacheTTL)
+	default:
+		return nil
+	}
+}
+
+// NewEmbedder 根据配置创建向量化，未启用时返回 nil
+func NewEmbedder(config *Config) llm.Embedder {
+	switch config.EmbeddingProvider {
+	case "openai":
+		model := config.EmbeddingModel
+		if model == "" {
+			model = "text-embedding-3-small"
+		}
+		return llm.NewOpenAIEmbedder(&llm.Config{
+			APIKey:     config.OpenAIAPIKey,
+			BaseURL:    config.OpenAIBaseURL,
+			Model:      model,
+			Timeout:    config.RequestTimeout,
+			MaxRetries: config.MaxRetries,
+			Dimensions: config.EmbeddingDimensions,
+		})
+	case "local":
+		model := config.EmbeddingModel
+		if model == "" {
+			model = "nomic-embed-text"
+		}
+		return llm.NewLocalEmbedder(&llm.Config{
+			BaseURL:    config.LocalBaseURL,
+			Model:      model,
+			Timeout:    config.RequestTimeout,
+			MaxRetries: config.MaxRetries,
+		})
+	default:
+		return nil
+	}
+}
+
+// newSemanticCache 根据配置创建语义缓存，未启用时返回 nil，创建失败时记录警告并返回 nil
+func newSemanticCache(config *Config) *llm.SemanticCache {
+	if !config.SemanticCache {
+		return nil
+	}
+
+	embedder := NewEmbedder(config)
+	if embedder == nil {
+		logrus.Warnf("Semantic cache disabled: unsupported embedding provider %q", config.EmbeddingProvider)
+		return nil
+	}
+
+	cache, err := llm.NewSemanticCache(&llm.SemanticCacheConfig{
+		Embed:      llm.EmbedFuncOf(embedder),
+		Threshold:  config.SemanticCacheThreshold,
+		MaxEntries: config.SemanticCacheSize,
+		TTL:        config.SemanticCacheTTL,
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("Semantic cache disabled: failed to create cache")
+		return nil
+	}
+	return cache
+}
+
+// ConfigureRegistry 根据配置为注册表设置各提供者的配置
+//
+// 默认提供者（LLM_PROVIDER）总会被配置；其余提供者在填写了对应凭证时才会被配置，
+// 这样模型名（如 claude-3-haiku、ollama/qwen2）可以直接路由到对应后端。
+func ConfigureRegistry(registry *llm.Registry, config *Config) {
+	base := llm.Config{
+		Temperature: config.OpenAITemperature,
+		MaxTokens:   config.OpenAIMaxTokens,
+		Timeout:     config.RequestTimeout,
+		MaxRetries:  config.MaxRetries,
+
+		PoolStrategy: llm.PoolStrategy(config.PoolStrategy),
+		PoolCooldown: config.PoolCooldown,
+
+		RequestsPerMinute: config.RequestsPerMinute,
+		TokensPerMinute:   config.TokensPerMinute,
+		MaxInFlight:       config.MaxInFlight,
+
+		Cache:      newCacheStore(config),
+		CacheForce: config.CacheForce,
+	}
+
+	defaultType := llm.ModelType(config.LLMProvider)
+	configure := func(modelType llm.ModelType, hasCredentials bool, fill func(c *llm.Config)) {
+		if modelType != defaultType && !hasCredentials {
+			return
+		}
+		c := base
+		fill(&c)
+		// 熔断器和语义缓存按后端分别创建，各后端的状态和命中统计互不影响
+		c.Breaker = newCircuitBreaker(config)
+		c.SemanticCache = newSemanticCache(config)
+		registry.Configure(modelType, &c)
+	}
+
+	configure(llm.ModelTypeOpenAI, config.OpenAIAPIKey != "", func(c *llm.Config) {
+		c.APIKey = config.OpenAIAPIKey
+		c.BaseURL = config.OpenAIBaseURL
+		c.Model = config.OpenAIModel
+		c.APIKeys = config.OpenAIAPIKeys
+		c.KeyWeights = config.OpenAIAPIKeyWeights
+	})
+	configure(llm.ModelTypeAzure, config.AzureOpenAIAPIKey != "" && config.AzureOpenAIEndpoint != "", func(c *llm.Config) {
+		c.APIKey = config.AzureOpenAIAPIKey
+		c.BaseURL = config.AzureOpenAIEndpoint
+		c.Model = config.OpenAIModel
+		c.APIVersion = config.AzureOpenAIAPIVersion
+		c.Deployments = config.AzureOpenAIDeployments
+	})
+	configure(llm.ModelTypeClaude, config.ClaudeAPIKey != "", func(c *llm.Config) {
+		c.APIKey = config.ClaudeAPIKey
+		c.BaseURL = config.ClaudeBaseURL
+		c.Model = config.ClaudeModel
+	})
+	// 本地模型无需凭证，且没有注册模型名前缀，只会通过 "ollama/模型名" 或默认提供者访问
+	configure(llm.ModelTypeLocal, config.LocalBaseURL != "", func(c *llm.Config) {
+		c.BaseURL = config.LocalBaseURL
+		c.Model = config.LocalModel
+		c.AutoPull = config.LocalAutoPull
+	})
+	configure(llm.ModelTypeBaidu, config.BaiduAPIKey != "" && config.BaiduSecretKey != "", func(c *llm.Config) {
+		c.APIKey = config.BaiduAPIKey
+		c.SecretKey = config.BaiduSecretKey
+		c.Model = config.BaiduModel
+	})
+
+	registry.SetDefault(defaultType)
+	registry.SetFallbacks(defaultType, config.LLMFallbacks...)
+}